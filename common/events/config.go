@@ -0,0 +1,34 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"errors"
+	"time"
+)
+
+type EventsConfig struct {
+	Enabled           bool          `env:"ENABLED, default=false"`          // Record K8s Events on pods waiting for the lock
+	ThrottledInterval time.Duration `env:"THROTTLED_INTERVAL, default=30s"` // Least interval of StartupThrottled events on the same pod
+}
+
+func (c EventsConfig) Validate() error {
+	if c.ThrottledInterval < 0 {
+		return errors.New("throttled events interval is lesser than 0")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	log "log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	ReasonStartupThrottled    = "StartupThrottled"
+	ReasonStartupLockAcquired = "StartupLockAcquired"
+)
+
+// waitTtl is how long a pod which stopped waiting without acquiring the lock is remembered, like a deleted one.
+const waitTtl = 10 * time.Minute
+
+// PodRef identifies the pod waiting for the lock, events without UID are not shown by kubectl describe pod.
+type PodRef struct {
+	Namespace string
+	Name      string
+	Uid       string
+}
+
+type podWait struct {
+	firstSeen     time.Time
+	lastSeen      time.Time
+	lastThrottled time.Time
+}
+
+// PodEvents records lock waits and grants on the waiting pods, StartupThrottled events are rate-limited per pod.
+// Methods of nil PodEvents do nothing, so callers need not check events are enabled.
+type PodEvents struct {
+	conf     EventsConfig
+	recorder *Recorder
+	mutex    sync.Mutex
+	waits    map[PodRef]*podWait
+}
+
+func NewPodEvents(conf EventsConfig, recorder *Recorder) *PodEvents {
+	events := &PodEvents{conf: conf, recorder: recorder, waits: map[PodRef]*podWait{}}
+	log.Info("configured pod events", log.String("throttled-interval", conf.ThrottledInterval.String()))
+	return events
+}
+
+// Throttled records the pod is denied the lock for the reason. Position in the queue is 1-based, 0 if unknown.
+func (e *PodEvents) Throttled(ctx context.Context, pod PodRef, reason string, position int) {
+	if e == nil || pod.Namespace == "" || pod.Name == "" {
+		return
+	}
+	now := time.Now()
+	e.mutex.Lock()
+	wait := e.seen(pod, now)
+	limited := !wait.lastThrottled.IsZero() && now.Sub(wait.lastThrottled) < e.conf.ThrottledInterval
+	if !limited {
+		wait.lastThrottled = now
+	}
+	e.mutex.Unlock()
+	if limited {
+		return
+	}
+
+	message := "Waiting for startup lock: " + reason
+	if position > 0 {
+		message += fmt.Sprintf(", position %d in queue", position)
+	}
+	e.recorder.Record(ctx, podReference(pod), EventTypeWarning, ReasonStartupThrottled, message)
+}
+
+// Acquired records the pod acquired the lock, with the time it waited since it was first denied.
+func (e *PodEvents) Acquired(ctx context.Context, pod PodRef) {
+	if e == nil || pod.Namespace == "" || pod.Name == "" {
+		return
+	}
+	now := time.Now()
+	e.mutex.Lock()
+	waited := now.Sub(e.seen(pod, now).firstSeen)
+	delete(e.waits, pod)
+	e.mutex.Unlock()
+
+	message := "Acquired startup lock without waiting"
+	if waited > 0 {
+		message = fmt.Sprintf("Acquired startup lock after waiting %s", waited.Round(time.Millisecond))
+	}
+	e.recorder.Record(ctx, podReference(pod), EventTypeNormal, ReasonStartupLockAcquired, message)
+}
+
+// Flush waits until the events recorded so far are created or the context is done.
+func (e *PodEvents) Flush(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	return e.recorder.Flush(ctx)
+}
+
+// seen returns wait of the pod, starting new one if there is none, and forgets pods which stopped waiting.
+// The caller must hold the mutex.
+func (e *PodEvents) seen(pod PodRef, now time.Time) *podWait {
+	for ref, wait := range e.waits {
+		if now.Sub(wait.lastSeen) > waitTtl {
+			delete(e.waits, ref)
+		}
+	}
+	wait, found := e.waits[pod]
+	if !found {
+		wait = &podWait{firstSeen: now}
+		e.waits[pod] = wait
+	}
+	wait.lastSeen = now
+	return wait
+}
+
+func podReference(pod PodRef) core.ObjectReference {
+	return core.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  pod.Namespace,
+		Name:       pod.Name,
+		UID:        types.UID(pod.Uid),
+	}
+}
@@ -0,0 +1,111 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+var testPod = PodRef{Namespace: "default", Name: "app-0", Uid: "5c2d"}
+
+func newTestPodEvents(t *testing.T, interval time.Duration) (*PodEvents, *fake.Clientset) {
+	k8s := fake.NewClientset()
+	recorder, err := NewRecorder(k8s, "psl-lock")
+	require.NoError(t, err)
+	return NewPodEvents(EventsConfig{Enabled: true, ThrottledInterval: interval}, recorder), k8s
+}
+
+func listEvents(t *testing.T, events *PodEvents, k8s *fake.Clientset) []core.Event {
+	require.NoError(t, events.Flush(context.Background()))
+	list, err := k8s.CoreV1().Events(testPod.Namespace).List(context.Background(), meta.ListOptions{})
+	require.NoError(t, err)
+	return list.Items
+}
+
+func TestPodEventsThrottledIsRateLimited(t *testing.T) {
+	// GIVEN
+	events, k8s := newTestPodEvents(t, time.Hour)
+
+	// WHEN
+	events.Throttled(context.Background(), testPod, "no free lock", 3)
+	events.Throttled(context.Background(), testPod, "no free lock", 2)
+
+	// THEN
+	recorded := listEvents(t, events, k8s)
+	require.Len(t, recorded, 1)
+	require.Equal(t, ReasonStartupThrottled, recorded[0].Reason)
+	require.Equal(t, core.EventTypeWarning, recorded[0].Type)
+	require.Equal(t, "Waiting for startup lock: no free lock, position 3 in queue", recorded[0].Message)
+	require.Equal(t, "Pod", recorded[0].InvolvedObject.Kind)
+	require.EqualValues(t, "5c2d", recorded[0].InvolvedObject.UID)
+	require.EqualValues(t, 1, recorded[0].Count)
+}
+
+func TestPodEventsThrottledUpdatesSeries(t *testing.T) {
+	// GIVEN
+	events, k8s := newTestPodEvents(t, 0)
+
+	// WHEN
+	events.Throttled(context.Background(), testPod, "no free lock", 3)
+	events.Throttled(context.Background(), testPod, "no free lock", 2)
+
+	// THEN
+	recorded := listEvents(t, events, k8s)
+	require.Len(t, recorded, 1)
+	require.Equal(t, "Waiting for startup lock: no free lock, position 2 in queue", recorded[0].Message)
+	require.EqualValues(t, 2, recorded[0].Count)
+}
+
+func TestPodEventsAcquiredTellsWait(t *testing.T) {
+	// GIVEN
+	events, k8s := newTestPodEvents(t, time.Hour)
+	events.Throttled(context.Background(), testPod, "no free lock", 0)
+	time.Sleep(10 * time.Millisecond)
+
+	// WHEN
+	events.Acquired(context.Background(), testPod)
+	events.Acquired(context.Background(), PodRef{Namespace: "default", Name: "app-1"})
+
+	// THEN
+	recorded := listEvents(t, events, k8s)
+	require.Len(t, recorded, 3)
+	messages := map[string]string{}
+	for _, event := range recorded {
+		if event.Reason == ReasonStartupLockAcquired {
+			messages[event.InvolvedObject.Name] = event.Message
+		}
+	}
+	require.Regexp(t, `^Acquired startup lock after waiting \d+ms$`, messages["app-0"])
+	require.Equal(t, "Acquired startup lock without waiting", messages["app-1"])
+}
+
+func TestPodEventsIfDisabled(t *testing.T) {
+	// GIVEN
+	var events *PodEvents
+
+	// WHEN
+	// THEN
+	events.Throttled(context.Background(), testPod, "no free lock", 1)
+	events.Acquired(context.Background(), testPod)
+	require.NoError(t, events.Flush(context.Background()))
+}
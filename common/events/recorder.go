@@ -0,0 +1,179 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import (
+	"context"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	log "log/slog"
+	"os"
+	"time"
+)
+
+// seriesTtl is how long repeated events of the same object and reason update the first one instead of creating new.
+const seriesTtl = 10 * time.Minute
+
+// Events are queued and recorded one by one in background, events beyond the queue are dropped.
+const (
+	maxQueuedEvents = 1000
+	eventTimeout    = 5 * time.Second // Bounds K8s API calls recording single event
+)
+
+const (
+	EventTypeNormal  = core.EventTypeNormal
+	EventTypeWarning = core.EventTypeWarning
+)
+
+// Recorder creates K8s Events in background, so that K8s API latency does not delay callers.
+// Short-living clients flush it before exit, not to lose queued events.
+type Recorder struct {
+	k8s       kubernetes.Interface
+	component string
+	host      string
+	queue     chan queuedEvent
+	series    map[string]*core.Event // Last events by object and reason, used by the worker only
+}
+
+// queuedEvent is the event to record, or the flush request to acknowledge once all events queued before are recorded.
+type queuedEvent struct {
+	ctx       context.Context
+	object    core.ObjectReference
+	eventType string
+	reason    string
+	message   string
+	flushed   chan struct{}
+}
+
+// NewRecorder creates recorder of events reported by the component and starts recording them. It uses the given
+// K8s client, or in-cluster one if the client is nil.
+func NewRecorder(k8s kubernetes.Interface, component string) (*Recorder, error) {
+	if k8s == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure K8s client for events: %w", err)
+		}
+		k8s, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure K8s client for events: %w", err)
+		}
+	}
+	host, _ := os.Hostname()
+	recorder := &Recorder{
+		k8s:       k8s,
+		component: component,
+		host:      host,
+		queue:     make(chan queuedEvent, maxQueuedEvents),
+		series:    map[string]*core.Event{},
+	}
+	go recorder.run()
+	log.Info("configured event recorder", log.String("component", component))
+	return recorder, nil
+}
+
+// Record queues the event on the object, which is created, or updates count and message of the previous event
+// of the same reason. Failures and dropped events are logged only, events are informational.
+func (r *Recorder) Record(ctx context.Context, object core.ObjectReference, eventType, reason, message string) {
+	select {
+	case r.queue <- queuedEvent{ctx: ctx, object: object, eventType: eventType, reason: reason, message: message}:
+	default:
+		log.WarnContext(ctx, "event queue is full, dropping event", log.String("reason", reason))
+	}
+}
+
+// Flush waits until the events queued so far are recorded or the context is done.
+func (r *Recorder) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case r.queue <- queuedEvent{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run records queued events one by one.
+func (r *Recorder) run() {
+	for event := range r.queue {
+		if event.flushed != nil {
+			close(event.flushed)
+			continue
+		}
+		// the caller may be done already, like the request the event is about
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(event.ctx), eventTimeout)
+		r.record(ctx, event.object, event.eventType, event.reason, event.message)
+		cancel()
+	}
+}
+
+func (r *Recorder) record(ctx context.Context, object core.ObjectReference, eventType, reason, message string) {
+	now := time.Now()
+	for key, event := range r.series {
+		if now.Sub(event.LastTimestamp.Time) > seriesTtl {
+			delete(r.series, key)
+		}
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s/%s", object.Kind, object.Namespace, object.Name, object.UID, reason)
+	if previous, found := r.series[key]; found {
+		event := previous.DeepCopy()
+		event.Count++
+		event.Message = message
+		event.Type = eventType
+		event.LastTimestamp = meta.NewTime(now)
+		updated, err := r.k8s.CoreV1().Events(event.Namespace).Update(ctx, event, meta.UpdateOptions{})
+		if err == nil {
+			r.series[key] = updated
+			return
+		}
+		if !apierrors.IsNotFound(err) {
+			log.WarnContext(ctx, "failed to update event", log.String("reason", reason), log.Any("error", err))
+			return
+		}
+		// the event has expired, start new series
+	}
+
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = meta.NamespaceDefault // of cluster-scoped objects, like nodes
+	}
+	event := &core.Event{
+		ObjectMeta:     meta.ObjectMeta{Name: fmt.Sprintf("%s.%x", object.Name, now.UnixNano()), Namespace: namespace},
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         core.EventSource{Component: r.component, Host: r.host},
+		FirstTimestamp: meta.NewTime(now),
+		LastTimestamp:  meta.NewTime(now),
+		Count:          1,
+	}
+	created, err := r.k8s.CoreV1().Events(namespace).Create(ctx, event, meta.CreateOptions{})
+	if err != nil {
+		log.WarnContext(ctx, "failed to create event", log.String("reason", reason), log.Any("error", err))
+		return
+	}
+	r.series[key] = created
+}
@@ -21,6 +21,22 @@ import (
 )
 
 type HealthChecker interface {
+	Name() string
 	Run(ctx context.Context)
 	IsHealthy() bool
+	Reason() string // Explanation of the current health status
+}
+
+// HealthStatus is the latest result of a named health checker.
+type HealthStatus struct {
+	Name     string `json:"name"`
+	Healthy  bool   `json:"healthy"`
+	Required bool   `json:"required"`
+	Reason   string `json:"reason"`
+}
+
+// HealthReporter exposes results of the health checkers it aggregates.
+type HealthReporter interface {
+	HealthStatuses() []HealthStatus
+	AdvisoryReason() string // Failures of checkers which do not affect the health, empty if there are none
 }
@@ -0,0 +1,231 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	authn "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	log "log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+type usernameKey struct{}
+
+// errNotAuthenticated tells the token is reviewed and rejected, unlike failures to review it.
+var errNotAuthenticated = errors.New("token is not authenticated")
+
+// maxCachedReviews bounds the TokenReview cache, expired entries are pruned once it is reached.
+const maxCachedReviews = 1000
+
+// TokenReviewer authenticates bearer token and returns the username it belongs to.
+type TokenReviewer interface {
+	Review(ctx context.Context, token string) (string, error)
+}
+
+// Authenticator guards handlers with the configured auth mode.
+type Authenticator struct {
+	conf     AuthConfig
+	reviewer TokenReviewer
+}
+
+// NewAuthenticator creates authenticator. In "tokenreview" mode it uses the given K8s client,
+// or in-cluster one if the client is nil.
+func NewAuthenticator(conf AuthConfig, k8s kubernetes.Interface) (*Authenticator, error) {
+	authenticator := &Authenticator{conf: conf}
+	if conf.Mode == AuthTokenReview {
+		if k8s == nil {
+			config, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure K8s client for TokenReview: %w", err)
+			}
+			k8s, err = kubernetes.NewForConfig(config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure K8s client for TokenReview: %w", err)
+			}
+		}
+		authenticator.reviewer = newCachingReviewer(&k8sTokenReviewer{k8s, conf.Audiences}, conf.CacheTtl)
+	}
+	log.Info("configured authenticator", log.String("mode", conf.Mode))
+	return authenticator, nil
+}
+
+// Wrap returns handler which responds with 401 to unauthenticated and 403 to unauthorized callers.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	if a.conf.Mode == AuthNone {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, status, err := a.authorize(r)
+		if err != nil {
+			log.Warn("denied request",
+				log.String("client-ip", r.RemoteAddr),
+				log.Int("status", status),
+				log.Any("error", err))
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if username != "" {
+			r = r.WithContext(context.WithValue(r.Context(), usernameKey{}, username))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServiceAccountOf returns namespace and name of the service account which authenticated the request
+// in "tokenreview" mode.
+func ServiceAccountOf(ctx context.Context) (string, string, bool) {
+	username, _ := ctx.Value(usernameKey{}).(string)
+	serviceAccount, found := strings.CutPrefix(username, serviceAccountPrefix)
+	if !found {
+		return "", "", false
+	}
+	return strings.Cut(serviceAccount, ":")
+}
+
+// authorize returns the username of the caller, it is known in "tokenreview" mode only.
+func (a *Authenticator) authorize(r *http.Request) (string, int, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return "", http.StatusUnauthorized, errors.New("bearer token is missing")
+	}
+
+	switch a.conf.Mode {
+	case AuthToken:
+		expected, err := os.ReadFile(a.conf.TokenFile)
+		if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("failed to read auth token: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(strings.TrimSpace(string(expected)))) != 1 {
+			return "", http.StatusUnauthorized, errors.New("bearer token is invalid")
+		}
+		return "", http.StatusOK, nil
+	default:
+		username, err := a.reviewer.Review(r.Context(), token)
+		if err != nil {
+			return "", http.StatusUnauthorized, err
+		}
+		if !a.isAllowed(username) {
+			return "", http.StatusForbidden, fmt.Errorf("'%s' is not allowed", username)
+		}
+		return username, http.StatusOK, nil
+	}
+}
+
+// isAllowed permits any authenticated caller unless namespaces or service accounts are listed.
+func (a *Authenticator) isAllowed(username string) bool {
+	if len(a.conf.Namespaces) == 0 && len(a.conf.ServiceAccounts) == 0 {
+		return true
+	}
+	serviceAccount, found := strings.CutPrefix(username, serviceAccountPrefix)
+	if !found {
+		return false
+	}
+	namespace, name, found := strings.Cut(serviceAccount, ":")
+	if !found {
+		return false
+	}
+	return slices.Contains(a.conf.Namespaces, namespace) || slices.Contains(a.conf.ServiceAccounts, namespace+"/"+name)
+}
+
+func splitServiceAccount(str string) (string, string, error) {
+	namespace, name, found := strings.Cut(str, "/")
+	if !found || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("service account is not 'namespace/name': '%s'", str)
+	}
+	return namespace, name, nil
+}
+
+type k8sTokenReviewer struct {
+	k8s       kubernetes.Interface
+	audiences []string
+}
+
+func (r *k8sTokenReviewer) Review(ctx context.Context, token string) (string, error) {
+	review := &authn.TokenReview{Spec: authn.TokenReviewSpec{Token: token, Audiences: r.audiences}}
+	result, err := r.k8s.AuthenticationV1().TokenReviews().Create(ctx, review, meta.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to review token: %w", err)
+	}
+	if !result.Status.Authenticated {
+		return "", fmt.Errorf("%w: %s", errNotAuthenticated, result.Status.Error)
+	}
+	return result.Status.User.Username, nil
+}
+
+type cachedReview struct {
+	username string
+	err      error
+	expires  time.Time
+}
+
+// cachingReviewer caches results by token hash, so that repeated lock attempts do not load K8s API.
+// Only authenticated and rejected tokens are cached, failures to review are retried on the next request.
+type cachingReviewer struct {
+	reviewer TokenReviewer
+	ttl      time.Duration
+	mutex    sync.Mutex
+	reviews  map[[sha256.Size]byte]cachedReview
+}
+
+func newCachingReviewer(reviewer TokenReviewer, ttl time.Duration) *cachingReviewer {
+	return &cachingReviewer{reviewer: reviewer, ttl: ttl, reviews: map[[sha256.Size]byte]cachedReview{}}
+}
+
+func (r *cachingReviewer) Review(ctx context.Context, token string) (string, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	r.mutex.Lock()
+	cached, found := r.reviews[key]
+	r.mutex.Unlock()
+	if found && now.Before(cached.expires) {
+		return cached.username, cached.err
+	}
+
+	username, err := r.reviewer.Review(ctx, token)
+	if err != nil && !errors.Is(err, errNotAuthenticated) {
+		return username, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.reviews) >= maxCachedReviews {
+		for k, review := range r.reviews {
+			if now.After(review.expires) {
+				delete(r.reviews, k)
+			}
+		}
+	}
+	if len(r.reviews) < maxCachedReviews {
+		r.reviews[key] = cachedReview{username, err, now.Add(r.ttl)}
+	}
+	return username, err
+}
@@ -0,0 +1,203 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	authn "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func serve(handler http.Handler, token string) int {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+// newFakeK8s authenticates "valid-<username>" tokens as the username.
+func newFakeK8s(reviews *int) *fake.Clientset {
+	k8s := fake.NewClientset()
+	k8s.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authn.TokenReview)
+		if username, found := strings.CutPrefix(review.Spec.Token, "valid-"); found {
+			review.Status = authn.TokenReviewStatus{Authenticated: true, User: authn.UserInfo{Username: username}}
+		} else {
+			review.Status = authn.TokenReviewStatus{Error: "invalid token"}
+		}
+		return true, review, nil
+	})
+	return k8s
+}
+
+func TestAuthNonePassesThrough(t *testing.T) {
+	// GIVEN
+	authenticator, _ := NewAuthenticator(AuthConfig{Mode: AuthNone}, nil)
+
+	// WHEN
+	status := serve(authenticator.Wrap(okHandler), "")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestAuthTokenIfValid(t *testing.T) {
+	// GIVEN
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0o600))
+	authenticator, _ := NewAuthenticator(AuthConfig{Mode: AuthToken, TokenFile: tokenFile}, nil)
+	handler := authenticator.Wrap(okHandler)
+
+	// WHEN
+	validStatus := serve(handler, "secret")
+	invalidStatus := serve(handler, "guess")
+	missingStatus := serve(handler, "")
+
+	// THEN
+	require.Equal(t, http.StatusOK, validStatus)
+	require.Equal(t, http.StatusUnauthorized, invalidStatus)
+	require.Equal(t, http.StatusUnauthorized, missingStatus)
+}
+
+func TestAuthTokenReviewIfNamespaceAllowed(t *testing.T) {
+	// GIVEN
+	reviews := 0
+	conf := AuthConfig{Mode: AuthTokenReview, Namespaces: []string{"database"}, CacheTtl: time.Minute}
+	authenticator, _ := NewAuthenticator(conf, newFakeK8s(&reviews))
+	handler := authenticator.Wrap(okHandler)
+
+	// WHEN
+	allowedStatus := serve(handler, "valid-system:serviceaccount:database:postgres")
+	forbiddenStatus := serve(handler, "valid-system:serviceaccount:default:default")
+	invalidStatus := serve(handler, "forged")
+
+	// THEN
+	require.Equal(t, http.StatusOK, allowedStatus)
+	require.Equal(t, http.StatusForbidden, forbiddenStatus)
+	require.Equal(t, http.StatusUnauthorized, invalidStatus)
+}
+
+func TestAuthTokenReviewIfServiceAccountAllowed(t *testing.T) {
+	// GIVEN
+	reviews := 0
+	conf := AuthConfig{Mode: AuthTokenReview, ServiceAccounts: []string{"database/postgres"}, CacheTtl: time.Minute}
+	authenticator, _ := NewAuthenticator(conf, newFakeK8s(&reviews))
+	handler := authenticator.Wrap(okHandler)
+
+	// WHEN
+	allowedStatus := serve(handler, "valid-system:serviceaccount:database:postgres")
+	forbiddenStatus := serve(handler, "valid-system:serviceaccount:database:backup")
+
+	// THEN
+	require.Equal(t, http.StatusOK, allowedStatus)
+	require.Equal(t, http.StatusForbidden, forbiddenStatus)
+}
+
+func TestAuthTokenReviewIsCached(t *testing.T) {
+	// GIVEN
+	reviews := 0
+	conf := AuthConfig{Mode: AuthTokenReview, CacheTtl: time.Minute}
+	authenticator, _ := NewAuthenticator(conf, newFakeK8s(&reviews))
+	handler := authenticator.Wrap(okHandler)
+
+	// WHEN
+	serve(handler, "valid-system:serviceaccount:database:postgres")
+	serve(handler, "valid-system:serviceaccount:database:postgres")
+
+	// THEN
+	require.Equal(t, 1, reviews)
+}
+
+type failingReviewer struct{}
+
+func (failingReviewer) Review(context.Context, string) (string, error) {
+	return "", errors.New("API is unavailable")
+}
+
+func TestCachingReviewerIfFailed(t *testing.T) {
+	// GIVEN
+	reviewer := newCachingReviewer(failingReviewer{}, time.Minute)
+
+	// WHEN
+	_, err := reviewer.Review(context.Background(), "token")
+
+	// THEN
+	require.EqualError(t, err, "API is unavailable")
+	require.Empty(t, reviewer.reviews)
+}
+
+func TestAuthTokenReviewCachesRejected(t *testing.T) {
+	// GIVEN
+	reviews := 0
+	conf := AuthConfig{Mode: AuthTokenReview, CacheTtl: time.Minute}
+	authenticator, _ := NewAuthenticator(conf, newFakeK8s(&reviews))
+	handler := authenticator.Wrap(okHandler)
+
+	// WHEN
+	firstStatus := serve(handler, "invalid")
+	secondStatus := serve(handler, "invalid")
+
+	// THEN
+	require.Equal(t, http.StatusUnauthorized, firstStatus)
+	require.Equal(t, http.StatusUnauthorized, secondStatus)
+	require.Equal(t, 1, reviews)
+}
+
+func TestAuthConfigIfServiceAccountMalformed(t *testing.T) {
+	// GIVEN
+	conf := AuthConfig{Mode: AuthTokenReview, ServiceAccounts: []string{"postgres"}}
+
+	// WHEN
+	err := conf.Validate()
+
+	// THEN
+	require.EqualError(t, err, "service account is not 'namespace/name': 'postgres'")
+}
+
+func TestAuthTokenReviewPassesServiceAccount(t *testing.T) {
+	// GIVEN
+	reviews := 0
+	conf := AuthConfig{Mode: AuthTokenReview, CacheTtl: time.Minute}
+	authenticator, _ := NewAuthenticator(conf, newFakeK8s(&reviews))
+	var namespace, name string
+	handler := authenticator.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace, name, _ = ServiceAccountOf(r.Context())
+	}))
+
+	// WHEN
+	serve(handler, "valid-system:serviceaccount:database:postgres")
+
+	// THEN
+	require.Equal(t, "database", namespace)
+	require.Equal(t, "postgres", name)
+}
@@ -0,0 +1,77 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AuthNone        = "none"
+	AuthToken       = "token"
+	AuthTokenReview = "tokenreview"
+)
+
+type TlsConfig struct {
+	CertFile string `env:"CERT_FILE"` // Server certificate, TLS is disabled if empty
+	KeyFile  string `env:"KEY_FILE"`  // Server key
+}
+
+type AuthConfig struct {
+	Mode            string        `env:"MODE, default=none"`    // Authentication of callers: "none", "token" or "tokenreview"
+	TokenFile       string        `env:"TOKEN_FILE"`            // File with shared bearer token for "token" mode
+	Audiences       []string      `env:"AUDIENCES"`             // Audiences of service account tokens for "tokenreview" mode, API server audience if empty
+	Namespaces      []string      `env:"NAMESPACES"`            // Namespaces of service accounts allowed in "tokenreview" mode
+	ServiceAccounts []string      `env:"SERVICE_ACCOUNTS"`      // Service accounts allowed in "tokenreview" mode, as "namespace/name"
+	CacheTtl        time.Duration `env:"CACHE_TTL, default=1m"` // How long TokenReview results are cached
+}
+
+func (c TlsConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+func (c TlsConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("TLS certificate and key files must be given together")
+	}
+	return nil
+}
+
+func (c AuthConfig) Validate() error {
+	var modeError error
+	switch c.Mode {
+	case AuthNone, AuthTokenReview:
+	case AuthToken:
+		if c.TokenFile == "" {
+			modeError = errors.New("auth token file is required for token auth mode")
+		}
+	default:
+		modeError = fmt.Errorf("unknown auth mode: '%s'", c.Mode)
+	}
+	var cacheTtlError error
+	if c.CacheTtl < 0 {
+		cacheTtlError = errors.New("auth cache TTL is lesser than 0")
+	}
+	var serviceAccountsError error
+	for _, sa := range c.ServiceAccounts {
+		if _, _, err := splitServiceAccount(sa); err != nil {
+			serviceAccountsError = errors.Join(serviceAccountsError, err)
+		}
+	}
+	return errors.Join(modeError, cacheTtlError, serviceAccountsError)
+}
@@ -0,0 +1,88 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	log "log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader loads server certificate again once its files change, so rotated certificates,
+// like ones issued by cert-manager, are served without restart.
+type certReloader struct {
+	conf     TlsConfig
+	mutex    sync.Mutex
+	modTimes [2]time.Time
+	cert     *tls.Certificate
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certInfo, err := os.Stat(r.conf.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(r.conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	modTimes := [2]time.Time{certInfo.ModTime(), keyInfo.ModTime()}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cert != nil && modTimes == r.modTimes {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		if r.cert != nil {
+			// files may be replaced one by one, keep serving the previous pair meanwhile
+			log.Warn("failed to reload server certificate, using previous one", log.Any("error", err))
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert, r.modTimes = &cert, modTimes
+	log.Info("loaded server certificate", log.String("cert-file", r.conf.CertFile))
+	return r.cert, nil
+}
+
+// NewTlsConfig creates server TLS config, or nil if TLS is disabled.
+func NewTlsConfig(conf TlsConfig) (*tls.Config, error) {
+	if !conf.Enabled() {
+		return nil, nil
+	}
+	reloader := &certReloader{conf: conf}
+	if _, err := reloader.getCertificate(nil); err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}, nil
+}
+
+// ListenAndServe serves HTTPS if the server has TLS config, and plain HTTP otherwise.
+func ListenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,92 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, commonName string, modTime time.Time) TlsConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	conf := TlsConfig{filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")}
+	require.NoError(t, os.WriteFile(conf.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0o600))
+	require.NoError(t, os.WriteFile(conf.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	require.NoError(t, os.Chtimes(conf.CertFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(conf.KeyFile, modTime, modTime))
+	return conf
+}
+
+func TestTlsConfigReloadsCertificate(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	conf := writeCert(t, dir, "initial", time.Now().Add(-time.Minute))
+	tlsConfig, err := NewTlsConfig(conf)
+	require.NoError(t, err)
+
+	// WHEN
+	writeCert(t, dir, "rotated", time.Now())
+	cert, err := tlsConfig.GetCertificate(nil)
+
+	// THEN
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "rotated", leaf.Subject.CommonName)
+}
+
+func TestTlsConfigIfDisabled(t *testing.T) {
+	// GIVEN
+	// WHEN
+	tlsConfig, err := NewTlsConfig(TlsConfig{})
+
+	// THEN
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+}
+
+func TestTlsConfigIfKeyMissing(t *testing.T) {
+	// GIVEN
+	conf := TlsConfig{CertFile: "/etc/tls/tls.crt"}
+
+	// WHEN
+	err := conf.Validate()
+
+	// THEN
+	require.EqualError(t, err, "TLS certificate and key files must be given together")
+}
@@ -18,59 +18,288 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	. "flakybit.net/psl/init/config"
 	"fmt"
 	"io"
+	"k8s.io/apimachinery/pkg/labels"
 	log "log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const maxIdleConnections = 1
 
+// Lease token and duration headers of successful lock response, denial reason and queue position headers of denied one.
+const (
+	headerLeaseToken    = "PSL-Lease-Token"
+	headerLeaseDuration = "PSL-Lease-Duration"
+	headerDenialReason  = "PSL-Denial-Reason"
+	headerQueuePosition = "PSL-Queue-Position"
+)
+
 type LockClient struct {
-	conf    Config
-	client  *http.Client
-	lockUrl string
+	conf      Config
+	client    *http.Client
+	lockUrl   string
+	podLabels string
 }
 
-func NewLockClient(conf Config) *LockClient {
+func NewLockClient(conf Config) (*LockClient, error) {
+	tlsConfig, err := newTlsConfig(conf)
+	if err != nil {
+		return nil, err
+	}
 	httpClient := &http.Client{
-		Transport: &http.Transport{MaxIdleConnsPerHost: maxIdleConnections},
+		Transport: &http.Transport{MaxIdleConnsPerHost: maxIdleConnections, TLSClientConfig: tlsConfig},
 		Timeout:   conf.Timeout,
 	}
+	scheme := "http"
+	if conf.Tls {
+		scheme = "https"
+	}
+	podLabels, err := readPodLabels(conf.PodLabels)
+	if err != nil {
+		return nil, err
+	}
 	client := &LockClient{
 		conf,
 		httpClient,
-		fmt.Sprintf("http://%s:%d", conf.LockHost, conf.LockPort),
+		fmt.Sprintf("%s://%s:%d", scheme, conf.LockHost, conf.LockPort),
+		podLabels,
 	}
 	log.Info("configured Lock client", log.String("lock-url", client.lockUrl))
-	return client
+	return client, nil
+}
+
+func newTlsConfig(conf Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if conf.Insecure {
+		log.Warn("Lock service certificate verification is disabled")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if conf.CaFile != "" {
+		caPem, err := os.ReadFile(conf.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Lock service CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("Lock service CA bundle '%s' has no certificates", conf.CaFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// readPodLabels reads Downward API labels file with `key="value"` lines and returns them as "key=value,..." list.
+func readPodLabels(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pod labels: %w", err)
+	}
+	podLabels := labels.Set{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		key, quoted, found := strings.Cut(line, "=")
+		value, err := strconv.Unquote(quoted)
+		if !found || err != nil {
+			return "", fmt.Errorf("pod labels file '%s' has malformed line: '%s'", path, line)
+		}
+		podLabels[key] = value
+	}
+	return podLabels.String(), nil
 }
 
-func (c *LockClient) AcquireLock(ctx context.Context) (bool, error) {
+// Lease is the acquired lock. Token is empty if the lock service did not return it, like for exempt workloads.
+type Lease struct {
+	Token    string
+	Duration time.Duration
+}
+
+// ErrLeaseLost tells the lease is expired or released already.
+var ErrLeaseLost = errors.New("lease is expired or released")
+
+// ErrRequestRejected tells the Lock service rejected the lock request as invalid or forbidden, retrying it does not help.
+var ErrRequestRejected = errors.New("lock request is rejected")
+
+// Denial tells why the lock is busy.
+type Denial struct {
+	Reason   string
+	Position int // 1-based position in the queue of the Lock service, 0 if unknown
+}
+
+// durationParam formats whole seconds as number of seconds, which Lock service of any version accepts,
+// and other durations in Go syntax, which Lock service of older versions rejects.
+func durationParam(duration time.Duration) string {
+	if duration%time.Second == 0 {
+		return strconv.FormatInt(int64(duration/time.Second), 10)
+	}
+	return duration.String()
+}
+
+// AcquireLock makes single attempt to acquire the lock, it returns the denial if the lock is busy.
+func (c *LockClient) AcquireLock(ctx context.Context) (Lease, *Denial, error) {
 	values := url.Values{}
 	if c.conf.LockDuration > 0 {
-		values.Add("duration", strconv.FormatFloat(c.conf.LockDuration.Seconds(), 'f', 0, 64))
+		values.Add("duration", durationParam(c.conf.LockDuration))
+	}
+	if c.conf.LockPool != "" {
+		values.Add("pool", c.conf.LockPool)
+	}
+	if c.conf.LockPriority != 0 {
+		values.Add("priority", strconv.Itoa(c.conf.LockPriority))
+	}
+	if c.conf.PodNamespace != "" {
+		values.Add("namespace", c.conf.PodNamespace)
+	}
+	if c.conf.PodName != "" {
+		values.Add("pod", c.conf.PodName)
+	}
+	if c.conf.PodUid != "" {
+		values.Add("uid", c.conf.PodUid)
+	}
+	if c.podLabels != "" {
+		values.Add("labels", c.podLabels)
+	}
+	lockUrl := c.lockUrl
+	if len(values) > 0 {
+		lockUrl += "?" + values.Encode()
 	}
-	request, err := http.NewRequestWithContext(ctx, "GET", c.lockUrl, strings.NewReader(values.Encode()))
+	request, err := http.NewRequestWithContext(ctx, "GET", lockUrl, nil)
 	if err != nil {
-		return false, err
+		return Lease{}, nil, err
 	}
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	log.Info("acquiring lock", log.String("url", request.URL.String()))
-	response, err := c.client.Do(request)
+	response, body, err := c.do(request)
 	if err != nil {
-		return false, err
+		return Lease{}, nil, err
+	}
+	if response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusForbidden {
+		return Lease{}, nil, fmt.Errorf("%w: %s", ErrRequestRejected, body)
+	}
+	if response.StatusCode != http.StatusOK {
+		denial := &Denial{Reason: response.Header.Get(headerDenialReason)}
+		if denial.Reason == "" {
+			// lock services before denial reasons tell only the status
+			denial.Reason = fmt.Sprintf("lock is busy, status %d", response.StatusCode)
+		}
+		denial.Position, _ = strconv.Atoi(response.Header.Get(headerQueuePosition))
+		return Lease{}, denial, nil
+	}
+
+	lease := Lease{Token: response.Header.Get(headerLeaseToken)}
+	if durationStr := response.Header.Get(headerLeaseDuration); durationStr != "" {
+		lease.Duration, err = time.ParseDuration(durationStr)
+		if err != nil {
+			// the lock is acquired anyway
+			log.Warn("lease duration is malformed", log.String("duration", durationStr), log.Any("error", err))
+		}
 	}
-	_, err = io.ReadAll(response.Body)
-	err = response.Body.Close()
+	return lease, nil, nil
+}
+
+// Heartbeat extends the lease, it returns ErrLeaseLost if the lease is expired or released.
+func (c *LockClient) Heartbeat(ctx context.Context, token string) error {
+	return c.postLease(ctx, "/heartbeat", token)
+}
+
+// Release frees the lease, it returns ErrLeaseLost if the lease is expired or released.
+func (c *LockClient) Release(ctx context.Context, token string) error {
+	return c.postLease(ctx, "/release", token)
+}
+
+func (c *LockClient) postLease(ctx context.Context, path string, token string) error {
+	values := url.Values{"token": {token}}
+	request, err := http.NewRequestWithContext(ctx, "POST", c.lockUrl+path, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, body, err := c.do(request)
 	if err != nil {
-		return false, err
+		return err
+	}
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrLeaseLost
+	default:
+		return fmt.Errorf("lease request failed with status %d: %s", response.StatusCode, body)
 	}
+}
 
-	return response.StatusCode == 200, nil
+// LockStatus is the state of the Lock service.
+type LockStatus struct {
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason"`
+	Pools   []struct {
+		Name    string `json:"name"`
+		Locks   int    `json:"locks"`
+		Limit   int    `json:"limit"`
+		Waiters int    `json:"waiters"`
+	} `json:"pools"`
+	Endpoints []struct {
+		Endpoint string `json:"endpoint"`
+		Optional bool   `json:"optional"`
+		Healthy  bool   `json:"healthy"`
+		Error    string `json:"error"`
+	} `json:"endpoints"`
+}
+
+// Status requests health and usage of the Lock service.
+func (c *LockClient) Status(ctx context.Context) (LockStatus, error) {
+	var status LockStatus
+	request, err := http.NewRequestWithContext(ctx, "GET", c.lockUrl+"/status", nil)
+	if err != nil {
+		return status, err
+	}
+	response, body, err := c.do(request)
+	if err != nil {
+		return status, err
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPreconditionFailed {
+		return status, fmt.Errorf("status request failed with status %d: %s", response.StatusCode, body)
+	}
+	err = json.Unmarshal(body, &status)
+	if err != nil {
+		return status, fmt.Errorf("status is malformed: %w", err)
+	}
+	return status, nil
+}
+
+// do authenticates the request and reads the response body.
+func (c *LockClient) do(request *http.Request) (*http.Response, []byte, error) {
+	if c.conf.TokenFile != "" {
+		// read on each attempt, since projected tokens are rotated
+		token, err := os.ReadFile(c.conf.TokenFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read token: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	err = errors.Join(err, response.Body.Close())
+	if err != nil {
+		return nil, nil, err
+	}
+	return response, body, nil
 }
@@ -0,0 +1,302 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/init/client"
+	. "flakybit.net/psl/init/config"
+	. "flakybit.net/psl/init/service"
+	"fmt"
+	"io"
+	log "log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	commandAcquire = "acquire"
+	commandStatus  = "status"
+	commandProbe   = "probe"
+	commandRelease = "release"
+)
+
+// Init containers must exit with 0 to let the pod start, so the wait timed out with proceeding does too,
+// the termination log tells them apart.
+const (
+	exitSuccess       = 0 // Command succeeded, the lock is acquired or released, the Lock service is healthy
+	exitNegative      = 1 // The lock is busy, the lease is lost or the Lock service is unhealthy
+	exitMisconfigured = 2 // Invalid command, flags or env, or the Lock service rejected the lock request
+	exitFailed        = 3 // Request to the Lock service failed
+	exitTimedOut      = 4 // The lock wait timed out and the app may not start without the lock
+	exitInterrupted   = 5 // Terminated while waiting for the lock
+)
+
+// exitResults name exit codes in the termination log, acquire writes its outcome instead.
+var exitResults = map[int]string{
+	exitNegative:      "negative",
+	exitMisconfigured: "misconfigured",
+	exitFailed:        "failed",
+}
+
+// eventsFlushTimeout bounds waiting for the recorded events on exit.
+const eventsFlushTimeout = 5 * time.Second
+
+// usageFormat is formatted with the program name, it is "app" in the image.
+const usageFormat = `Usage: %s [command] [flags]
+
+Commands:
+  acquire   Wait for the lock, default
+  status    Print health and usage of the Lock service
+  probe     Make single attempt to acquire the lock
+  release   Release the lease given by --token
+
+Flags override env vars of the same names, like --lock-host for PSL_LOCK_HOST.
+`
+
+func usage() string {
+	return fmt.Sprintf(usageFormat, filepath.Base(os.Args[0]))
+}
+
+// run executes the command given by the first argument and returns exit code.
+func run(ctx context.Context, args []string) int {
+	command := commandAcquire
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	terminationLog := TerminationLogOf(ctx, nil)
+	switch command {
+	case commandAcquire, commandStatus, commandProbe, commandRelease:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n%s", command, usage())
+		return terminate(terminationLog, exitMisconfigured, fmt.Errorf("unknown command '%s'", command))
+	}
+
+	flags, overrides := newFlagSet(command, os.Stderr)
+	var token string
+	if command == commandRelease {
+		flags.StringVar(&token, "token", "", "Lease token to release, as returned by probe")
+	}
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitSuccess
+	}
+	if err != nil {
+		return terminate(terminationLog, exitMisconfigured, err)
+	}
+	terminationLog = TerminationLogOf(ctx, overrides)
+	if command == commandRelease && token == "" {
+		fmt.Fprint(os.Stderr, "--token is required\n")
+		return terminate(terminationLog, exitMisconfigured, errors.New("--token is required"))
+	}
+
+	conf, err := NewConfigWithOverrides(ctx, overrides)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure application", log.Any("error", err))
+		return terminate(terminationLog, exitMisconfigured, fmt.Errorf("failed to configure application: %w", err))
+	}
+	lockClient, err := NewLockClient(conf)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure Lock client", log.Any("error", err))
+		return terminate(conf.TerminationLog, exitMisconfigured, fmt.Errorf("failed to configure Lock client: %w", err))
+	}
+
+	// output of the commands is written to the termination log as well, unless they succeed
+	var output strings.Builder
+	writer := io.MultiWriter(os.Stdout, &output)
+	code := exitSuccess
+	switch command {
+	case commandStatus:
+		code = status(ctx, lockClient, writer)
+	case commandProbe:
+		code = probe(ctx, lockClient, writer)
+	case commandRelease:
+		code = release(ctx, lockClient, token, writer)
+	default:
+		events := newPodEvents(conf)
+		lockService := NewLockService(conf, lockClient, events)
+		var outcome Outcome
+		if conf.Mode == ModeSidecar {
+			outcome = NewSidecarService(conf, lockClient, lockService).Run(ctx)
+		} else {
+			outcome = lockService.Run(ctx)
+		}
+		flushEvents(ctx, events)
+		return exitCodeOf(outcome)
+	}
+	if code != exitSuccess {
+		writeTerminationLog(conf.TerminationLog, code, output.String())
+	}
+	return code
+}
+
+// terminate writes the error to the termination log and returns the exit code.
+func terminate(terminationLog string, code int, err error) int {
+	writeTerminationLog(terminationLog, code, fmt.Sprintf("error: %v\n", err))
+	return code
+}
+
+// writeTerminationLog writes the result of the exit code with details, like LockService.Report writes the outcome.
+func writeTerminationLog(terminationLog string, code int, details string) {
+	if terminationLog == "" {
+		return
+	}
+	err := os.WriteFile(terminationLog, []byte(fmt.Sprintf("result: %s\n%s", exitResults[code], details)), 0644)
+	if err != nil {
+		log.Warn("failed to write termination log", log.String("path", terminationLog), log.Any("error", err))
+	}
+}
+
+// newPodEvents creates pod events if the init container records them, events are optional, so failure is logged only.
+func newPodEvents(conf Config) *PodEvents {
+	if !conf.Events.Enabled {
+		return nil
+	}
+	recorder, err := NewRecorder(nil, "psl-init")
+	if err != nil {
+		log.Error("failed to configure event recorder, events are not recorded", log.Any("error", err))
+		return nil
+	}
+	return NewPodEvents(conf.Events, recorder)
+}
+
+// flushEvents waits for the recorded events to be created before exit, even if the container is terminated.
+func flushEvents(ctx context.Context, events *PodEvents) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), eventsFlushTimeout)
+	defer cancel()
+	err := events.Flush(ctx)
+	if err != nil {
+		log.Warn("failed to flush events, some are not recorded", log.Any("error", err))
+	}
+}
+
+func exitCodeOf(outcome Outcome) int {
+	switch outcome.Result {
+	case ResultAcquired, ResultTimedOutProceeded:
+		return exitSuccess
+	case ResultTimedOutFailed:
+		return exitTimedOut
+	case ResultInterrupted:
+		return exitInterrupted
+	case ResultRejected:
+		return exitMisconfigured
+	}
+	panic(fmt.Sprintf("unknown lock result '%s'", outcome.Result))
+}
+
+// newFlagSet creates flags for all config env vars, given flags are collected as overrides by env var name.
+func newFlagSet(command string, output io.Writer) (*flag.FlagSet, map[string]string) {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprint(output, usage()+"\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	overrides := map[string]string{}
+	for _, envVar := range EnvVars() {
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(envVar.Name, "PSL_"), "_", "-"))
+		set := func(value string) error {
+			overrides[envVar.Name] = value
+			return nil
+		}
+		if envVar.Bool {
+			flags.BoolFunc(name, "Overrides "+envVar.Name, set)
+		} else {
+			flags.Func(name, "Overrides "+envVar.Name, set)
+		}
+	}
+	return flags, overrides
+}
+
+func status(ctx context.Context, client *LockClient, output io.Writer) int {
+	status, err := client.Status(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get Lock service status", log.Any("error", err))
+		return exitFailed
+	}
+	if status.Healthy {
+		fmt.Fprintln(output, "Lock service is healthy")
+	} else {
+		fmt.Fprintf(output, "Lock service is unhealthy: %s\n", status.Reason)
+	}
+	for _, pool := range status.Pools {
+		name := pool.Name
+		if name == "" {
+			name = "default"
+		}
+		fmt.Fprintf(output, "Pool %s: %d of %d locks acquired, %d waiting\n", name, pool.Locks, pool.Limit, pool.Waiters)
+	}
+	for _, endpoint := range status.Endpoints {
+		state := "healthy"
+		if !endpoint.Healthy {
+			state = "unhealthy: " + endpoint.Error
+		}
+		if endpoint.Optional {
+			state += " (optional)"
+		}
+		fmt.Fprintf(output, "Endpoint %s: %s\n", endpoint.Endpoint, state)
+	}
+	if !status.Healthy {
+		return exitNegative
+	}
+	return exitSuccess
+}
+
+func probe(ctx context.Context, client *LockClient, output io.Writer) int {
+	lease, denial, err := client.AcquireLock(ctx)
+	if errors.Is(err, ErrRequestRejected) {
+		log.ErrorContext(ctx, "lock request is invalid", log.Any("error", err))
+		return exitMisconfigured
+	}
+	if err != nil {
+		log.ErrorContext(ctx, "failed to acquire a lock", log.Any("error", err))
+		return exitFailed
+	}
+	if denial != nil && denial.Position > 0 {
+		fmt.Fprintf(output, "Lock is busy: %s, position %d in queue\n", denial.Reason, denial.Position)
+		return exitNegative
+	}
+	if denial != nil {
+		fmt.Fprintf(output, "Lock is busy: %s\n", denial.Reason)
+		return exitNegative
+	}
+	if lease.Token == "" {
+		fmt.Fprintln(output, "Lock acquired")
+	} else {
+		fmt.Fprintf(output, "Lock acquired for %s, lease token: %s\n", lease.Duration, lease.Token)
+	}
+	return exitSuccess
+}
+
+func release(ctx context.Context, client *LockClient, token string, output io.Writer) int {
+	err := client.Release(ctx, token)
+	if errors.Is(err, ErrLeaseLost) {
+		fmt.Fprintln(output, "Lease is expired or released already")
+		return exitNegative
+	}
+	if err != nil {
+		log.ErrorContext(ctx, "failed to release lease", log.Any("error", err))
+		return exitFailed
+	}
+	fmt.Fprintln(output, "Lease released")
+	return exitSuccess
+}
@@ -0,0 +1,162 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newStubLock serves lock requests with the status, and status requests with the lock service state.
+// Termination log is written to a temporary file, see readTerminationLog.
+func newStubLock(t *testing.T, lockStatus int) []string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			_, _ = w.Write([]byte(`{"healthy": true, "pools": [{"name": "", "locks": 1, "limit": 2, "waiters": 0}]}`))
+		case "/release":
+			if r.FormValue("token") != "lease-token" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		default:
+			w.WriteHeader(lockStatus)
+		}
+	}))
+	t.Cleanup(server.Close)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	t.Setenv("PSL_TERMINATION_LOG", filepath.Join(t.TempDir(), "termination-log"))
+	return []string{"--lock-host", host, "--lock-port", port}
+}
+
+func readTerminationLog(t *testing.T) string {
+	content, err := os.ReadFile(os.Getenv("PSL_TERMINATION_LOG"))
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestProbeIfAcquired(t *testing.T) {
+	// GIVEN
+	flags := newStubLock(t, http.StatusOK)
+
+	// WHEN
+	code := run(context.Background(), append([]string{commandProbe}, flags...))
+
+	// THEN
+	require.Equal(t, exitSuccess, code)
+}
+
+func TestProbeIfLocked(t *testing.T) {
+	// GIVEN
+	flags := newStubLock(t, http.StatusLocked)
+
+	// WHEN
+	code := run(context.Background(), append([]string{commandProbe}, flags...))
+
+	// THEN
+	require.Equal(t, exitNegative, code)
+	require.Equal(t, "result: negative\nLock is busy: lock is busy, status 423\n", readTerminationLog(t))
+}
+
+func TestStatusIfHealthy(t *testing.T) {
+	// GIVEN
+	flags := newStubLock(t, http.StatusOK)
+
+	// WHEN
+	code := run(context.Background(), append([]string{commandStatus}, flags...))
+
+	// THEN
+	require.Equal(t, exitSuccess, code)
+}
+
+func TestReleaseIfLeaseLost(t *testing.T) {
+	// GIVEN
+	flags := newStubLock(t, http.StatusOK)
+
+	// WHEN
+	releasedCode := run(context.Background(), append([]string{commandRelease, "--token", "lease-token"}, flags...))
+	lostCode := run(context.Background(), append([]string{commandRelease, "--token", "other-token"}, flags...))
+
+	// THEN
+	require.Equal(t, exitSuccess, releasedCode)
+	require.Equal(t, exitNegative, lostCode)
+}
+
+func TestRunIfMisconfigured(t *testing.T) {
+	// GIVEN
+	terminationLog := filepath.Join(t.TempDir(), "termination-log")
+	t.Setenv("PSL_TERMINATION_LOG", terminationLog)
+
+	// WHEN
+	unknownCode := run(context.Background(), []string{"unknown"})
+	unknownMessage := readTerminationLog(t)
+	invalidCode := run(context.Background(), []string{commandProbe, "--lock-host", "lock", "--lock-port", "http"})
+	invalidMessage := readTerminationLog(t)
+
+	// THEN
+	require.Equal(t, exitMisconfigured, unknownCode)
+	require.Equal(t, "result: misconfigured\nerror: unknown command 'unknown'\n", unknownMessage)
+	require.Equal(t, exitMisconfigured, invalidCode)
+	require.Contains(t, invalidMessage, "result: misconfigured\nerror: failed to configure application: ")
+}
+
+func TestRunIfLockClientMisconfigured(t *testing.T) {
+	// GIVEN
+	terminationLog := filepath.Join(t.TempDir(), "termination-log")
+
+	// WHEN
+	code := run(context.Background(), []string{commandProbe, "--lock-host", "lock", "--lock-tls",
+		"--lock-ca-file", filepath.Join(t.TempDir(), "missing.crt"), "--termination-log", terminationLog})
+
+	// THEN
+	require.Equal(t, exitMisconfigured, code)
+	content, err := os.ReadFile(terminationLog)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "result: misconfigured\nerror: failed to configure Lock client: ")
+}
+
+func TestRunIfRejected(t *testing.T) {
+	// GIVEN
+	flags := append(newStubLock(t, http.StatusBadRequest), "--lock-check-period", "5ms")
+
+	// WHEN
+	acquireCode := run(context.Background(), append([]string{commandAcquire}, flags...))
+	probeCode := run(context.Background(), append([]string{commandProbe}, flags...))
+
+	// THEN
+	require.Equal(t, exitMisconfigured, acquireCode)
+	require.Equal(t, exitMisconfigured, probeCode)
+}
+
+func TestAcquireIfTimedOut(t *testing.T) {
+	// GIVEN
+	flags := append(newStubLock(t, http.StatusLocked),
+		"--lock-check-period", "5ms", "--lock-wait-timeout", "20ms", "--termination-log", t.TempDir()+"/termination-log")
+
+	// WHEN
+	failedCode := run(context.Background(), append([]string{commandAcquire, "--lock-on-timeout", "fail"}, flags...))
+	proceededCode := run(context.Background(), append([]string{commandAcquire, "--lock-on-timeout", "proceed"}, flags...))
+
+	// THEN
+	require.Equal(t, exitTimedOut, failedCode)
+	require.Equal(t, exitSuccess, proceededCode)
+}
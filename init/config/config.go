@@ -19,26 +19,93 @@ package config
 import (
 	"context"
 	"errors"
+	. "flakybit.net/psl/common/events"
+	"fmt"
 	"github.com/sethvargo/go-envconfig"
 	log "log/slog"
+	"reflect"
+	"strings"
 	"time"
 )
 
+const (
+	ModeInit    = "init"
+	ModeSidecar = "sidecar"
+)
+
+const (
+	OnTimeoutProceed = "proceed"
+	OnTimeoutFail    = "fail"
+)
+
 type Config struct {
+	Mode         string        `env:"PSL_MODE, default=init"`             // "init" to exit once the lock is acquired, "sidecar" to hold it until the app is ready
 	LockHost     string        `env:"PSL_LOCK_HOST, required"`            // Lock service host
 	LockPort     int           `env:"PSL_LOCK_PORT, default=8080"`        // Lock service port
 	LockDuration time.Duration `env:"PSL_LOCK_DURATION"`                  // Custom lock duration to request
+	LockPool     string        `env:"PSL_LOCK_POOL"`                      // Pool to request the lock in, it must be defined by Lock service policy
+	LockPriority int           `env:"PSL_LOCK_PRIORITY"`                  // Priority of the lock request, higher is served first
 	Period       time.Duration `env:"PSL_LOCK_CHECK_PERIOD, default=3s"`  // Period of lock acquisition attempts
 	Timeout      time.Duration `env:"PSL_LOCK_CHECK_TIMEOUT, default=1s"` // Timeout of lock request
+	WaitTimeout  time.Duration `env:"PSL_LOCK_WAIT_TIMEOUT"`              // Time to wait for the lock, 0 waits indefinitely
+	OnTimeout    string        `env:"PSL_LOCK_ON_TIMEOUT, default=fail"`  // "proceed" to start the app without the lock once the wait times out, "fail" to exit with error
+	Tls          bool          `env:"PSL_LOCK_TLS, default=false"`        // Connect to Lock service with HTTPS
+	CaFile       string        `env:"PSL_LOCK_CA_FILE"`                   // CA bundle to verify Lock service certificate with, instead of system roots
+	Insecure     bool          `env:"PSL_LOCK_INSECURE, default=false"`   // Skip verification of Lock service certificate
+	TokenFile    string        `env:"PSL_LOCK_TOKEN_FILE"`                // File with bearer token to authenticate with, like projected service account token
+	PodNamespace string        `env:"PSL_POD_NAMESPACE"`                  // Namespace of the pod, to match Lock service policy
+	PodName      string        `env:"PSL_POD_NAME"`                       // Name of the pod, for Lock service logs
+	PodLabels    string        `env:"PSL_POD_LABELS_FILE"`                // Downward API file with labels of the pod, to match Lock service policy
+	PodUid       string        `env:"PSL_POD_UID"`                        // UID of the pod, to show its events in kubectl describe pod
+
+	TerminationLog string `env:"PSL_TERMINATION_LOG, default=/dev/termination-log"` // File to write the outcome to, shown by kubectl describe pod, empty disables
+
+	Sidecar SidecarConfig `env:", prefix=PSL_SIDECAR_"`
+	Events  EventsConfig  `env:", prefix=PSL_EVENTS_"` // Record events by the init container instead of Lock service
+}
+
+type SidecarConfig struct {
+	ReadinessUrl    string        `env:"READINESS_URL"`                // App endpoint on localhost, the lock is released once it responds with success
+	ReadinessPeriod time.Duration `env:"READINESS_PERIOD, default=2s"` // Period of app readiness checks
+	ProbePort       int           `env:"PROBE_PORT, default=8081"`     // Port to serve startup probe on, it succeeds once the lock is acquired
+}
+
+// EnvVar describes env var of the config, so that it may be given as command line flag too.
+type EnvVar struct {
+	Name string
+	Bool bool // Whether the value is boolean, so the flag may be given without value
+}
+
+// EnvVars lists env vars of the config in order of fields.
+func EnvVars() []EnvVar {
+	return appendEnvVars(nil, "", reflect.TypeOf(Config{}))
+}
+
+func appendEnvVars(envVars []EnvVar, prefix string, structType reflect.Type) []EnvVar {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if nestedPrefix, found := strings.CutPrefix(strings.TrimSpace(options), "prefix="); found && field.Type.Kind() == reflect.Struct {
+			envVars = appendEnvVars(envVars, prefix+nestedPrefix, field.Type)
+		} else if name != "" {
+			envVars = append(envVars, EnvVar{prefix + name, field.Type.Kind() == reflect.Bool})
+		}
+	}
+	return envVars
 }
 
 func NewConfig(ctx context.Context) (Config, error) {
+	return NewConfigWithOverrides(ctx, nil)
+}
+
+// NewConfigWithOverrides reads the config from env, the overrides by env var name take precedence.
+func NewConfigWithOverrides(ctx context.Context, overrides map[string]string) (Config, error) {
 	var conf Config
-	err := envconfig.Process(ctx, &conf)
+	err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: &conf, Lookuper: lookuperOf(overrides)})
 	if err != nil {
 		return conf, err
 	}
-	err = conf.validate()
+	err = conf.Validate()
 	if err != nil {
 		return conf, err
 	}
@@ -46,14 +113,62 @@ func NewConfig(ctx context.Context) (Config, error) {
 	return conf, err
 }
 
-func (c *Config) validate() error {
+// TerminationLogOf reads only the termination log file of the config, to report failures to read the whole config.
+// It is empty if the file is not readable either.
+func TerminationLogOf(ctx context.Context, overrides map[string]string) string {
+	field, _ := reflect.TypeOf(Config{}).FieldByName("TerminationLog")
+	target := reflect.New(reflect.StructOf([]reflect.StructField{{Name: field.Name, Type: field.Type, Tag: field.Tag}}))
+	err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: target.Interface(), Lookuper: lookuperOf(overrides)})
+	if err != nil {
+		return ""
+	}
+	return target.Elem().Field(0).String()
+}
+
+func lookuperOf(overrides map[string]string) envconfig.Lookuper {
+	return envconfig.MultiLookuper(envconfig.MapLookuper(overrides), envconfig.OsLookuper())
+}
+
+// Validate checks the config as init reads it.
+func (c *Config) Validate() error {
+	var readinessError error
+	if c.Mode == ModeSidecar && c.Sidecar.ReadinessUrl == "" {
+		readinessError = errors.New("sidecar mode requires app readiness URL")
+	}
+	return errors.Join(c.ValidateCommon(), readinessError)
+}
+
+// ValidateCommon checks the config except the app readiness URL, which the webhook may take from pod annotations.
+func (c *Config) ValidateCommon() error {
 	var periodError error
-	if c.Period < 0 {
-		periodError = errors.New("lock check period is lesser than 0")
+	if c.Period <= 0 {
+		periodError = errors.New("lock check period is not positive")
 	}
 	var timeoutError error
-	if c.Period < 0 {
+	if c.Timeout < 0 {
 		timeoutError = errors.New("check timeout is lesser than 0")
 	}
-	return errors.Join(periodError, timeoutError)
+	var waitError error
+	if c.WaitTimeout < 0 {
+		waitError = errors.New("lock wait timeout is lesser than 0")
+	}
+	if c.OnTimeout != OnTimeoutProceed && c.OnTimeout != OnTimeoutFail {
+		waitError = errors.Join(waitError,
+			fmt.Errorf("on timeout action '%s' is neither '%s' nor '%s'", c.OnTimeout, OnTimeoutProceed, OnTimeoutFail))
+	}
+	var tlsError error
+	if c.CaFile != "" && c.Insecure {
+		tlsError = errors.New("cannot specify both Lock service CA bundle and insecure connection")
+	}
+	var modeError error
+	switch c.Mode {
+	case ModeInit:
+	case ModeSidecar:
+		if c.Sidecar.ReadinessPeriod <= 0 {
+			modeError = errors.New("readiness period is not positive")
+		}
+	default:
+		modeError = fmt.Errorf("mode '%s' is neither '%s' nor '%s'", c.Mode, ModeInit, ModeSidecar)
+	}
+	return errors.Join(periodError, timeoutError, waitError, tlsError, modeError, c.Events.Validate())
 }
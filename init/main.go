@@ -18,30 +18,22 @@ package main
 
 import (
 	"context"
-	. "flakybit.net/psl/init/client"
-	. "flakybit.net/psl/init/config"
-	. "flakybit.net/psl/init/service"
 	slogenv "github.com/cbrewster/slog-env"
 	log "log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	var err error
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 
 	logHandler := slogenv.NewHandler(
 		log.NewTextHandler(os.Stderr, nil),
 		slogenv.WithEnvVarName("PSL_LOG"))
 	log.SetDefault(log.New(logHandler))
 
-	conf, err := NewConfig(ctx)
-	if err != nil {
-		log.ErrorContext(ctx, "failed to configure application", log.Any("error", err))
-		panic(err)
-	}
-
-	lockClient := NewLockClient(conf)
-	lockService := NewLockService(conf, lockClient)
-	lockService.Run(ctx)
+	code := run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
 }
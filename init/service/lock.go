@@ -18,45 +18,99 @@ package service
 
 import (
 	"context"
+	"errors"
+	. "flakybit.net/psl/common/events"
 	. "flakybit.net/psl/init/client"
 	. "flakybit.net/psl/init/config"
 	log "log/slog"
+	"os"
 	"time"
 )
 
 type LockService struct {
 	conf   Config
 	client *LockClient
+	events *PodEvents // Nil unless the init container records events instead of Lock service
 }
 
-func NewLockService(conf Config, client *LockClient) *LockService {
+func NewLockService(conf Config, client *LockClient, events *PodEvents) *LockService {
 	hcSvc := LockService{
 		conf,
 		client,
+		events,
 	}
 	log.Info("configured lock service")
 	return &hcSvc
 }
 
-func (ls *LockService) Run(ctx context.Context) {
+// Run acquires the lock and reports the outcome.
+func (ls *LockService) Run(ctx context.Context) Outcome {
+	outcome := ls.Acquire(ctx)
+	ls.Report(outcome)
+	return outcome
+}
+
+// Acquire attempts to acquire the lock every period until succeeded, the wait times out, the context is done
+// or the Lock service rejects the request.
+func (ls *LockService) Acquire(ctx context.Context) Outcome {
 	ticker := time.NewTicker(ls.conf.Period)
 	defer ticker.Stop()
+	var timeout <-chan time.Time
+	if ls.conf.WaitTimeout > 0 {
+		timer := time.NewTimer(ls.conf.WaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
+	pod := PodRef{Namespace: ls.conf.PodNamespace, Name: ls.conf.PodName, Uid: ls.conf.PodUid}
+	outcome := Outcome{started: time.Now()}
 	for {
-		success, err := ls.client.AcquireLock(ctx)
-		if err != nil {
+		lease, denial, err := ls.client.AcquireLock(ctx)
+		outcome.Attempts++
+		if errors.Is(err, ErrRequestRejected) {
+			log.ErrorContext(ctx, "lock request is invalid, giving up", log.Any("error", err))
+			outcome.deny(err.Error())
+			return outcome.finish(ResultRejected, Lease{})
+		} else if err != nil {
 			log.ErrorContext(ctx, "failed to acquire a lock", log.Any("error", err))
-		}
-		if success {
+			outcome.deny("request failed: " + err.Error())
+		} else if denial != nil {
+			log.Info("lock is busy", log.String("reason", denial.Reason), log.Int("position", denial.Position))
+			outcome.deny(denial.Reason)
+			ls.events.Throttled(ctx, pod, denial.Reason, denial.Position)
+		} else {
 			log.Info("lock acquired successfully")
-			return
+			ls.events.Acquired(ctx, pod)
+			return outcome.finish(ResultAcquired, lease)
 		}
 
 		select {
 		case <-ticker.C:
 			continue
+		case <-timeout:
+			if ls.conf.OnTimeout == OnTimeoutProceed {
+				log.Warn("lock wait timed out, proceeding without the lock", log.String("timeout", ls.conf.WaitTimeout.String()))
+				return outcome.finish(ResultTimedOutProceeded, Lease{})
+			}
+			log.Error("lock wait timed out", log.String("timeout", ls.conf.WaitTimeout.String()))
+			return outcome.finish(ResultTimedOutFailed, Lease{})
 		case <-ctx.Done():
-			return
+			return outcome.finish(ResultInterrupted, Lease{})
 		}
 	}
 }
+
+// Report logs the outcome and writes it to the termination log if configured.
+func (ls *LockService) Report(outcome Outcome) {
+	log.Info("lock acquisition finished",
+		log.String("result", string(outcome.Result)),
+		log.String("wait", outcome.Wait.String()),
+		log.Int("attempts", outcome.Attempts))
+	if ls.conf.TerminationLog == "" {
+		return
+	}
+	err := os.WriteFile(ls.conf.TerminationLog, []byte(outcome.Message()), 0644)
+	if err != nil {
+		log.Warn("failed to write termination log", log.String("path", ls.conf.TerminationLog), log.Any("error", err))
+	}
+}
@@ -0,0 +1,129 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/init/client"
+	. "flakybit.net/psl/init/config"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestLockService denies the lock to the given number of attempts first.
+func newTestLockService(t *testing.T, denials int, conf Config) *LockService {
+	attempts := 0
+	lockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= denials {
+			w.Header().Set("PSL-Denial-Reason", "no free lock")
+			w.WriteHeader(http.StatusLocked)
+			return
+		}
+		w.Header().Set("PSL-Lease-Token", "lease-token")
+	}))
+	t.Cleanup(lockServer.Close)
+	host, port, _ := net.SplitHostPort(lockServer.Listener.Addr().String())
+	conf.LockHost = host
+	conf.LockPort, _ = strconv.Atoi(port)
+	conf.Period = 5 * time.Millisecond
+	conf.Timeout = time.Second
+	client, err := NewLockClient(conf)
+	require.NoError(t, err)
+	return NewLockService(conf, client, nil)
+}
+
+func TestLockServiceReportsAcquired(t *testing.T) {
+	// GIVEN
+	terminationLog := filepath.Join(t.TempDir(), "termination-log")
+	lockService := newTestLockService(t, 2, Config{TerminationLog: terminationLog})
+
+	// WHEN
+	outcome := lockService.Run(context.Background())
+
+	// THEN
+	require.Equal(t, ResultAcquired, outcome.Result)
+	require.Equal(t, 3, outcome.Attempts)
+	require.Equal(t, []DenialCount{{"no free lock", 2}}, outcome.Denials)
+	message, err := os.ReadFile(terminationLog)
+	require.NoError(t, err)
+	require.Contains(t, string(message), "result: acquired\n")
+	require.Contains(t, string(message), "attempts: 3\nlease-token: lease-token\ndenials:\n- 2x no free lock\n")
+}
+
+func TestLockServiceIfTimedOut(t *testing.T) {
+	// GIVEN
+	failing := newTestLockService(t, 1000, Config{WaitTimeout: 20 * time.Millisecond, OnTimeout: OnTimeoutFail})
+	proceeding := newTestLockService(t, 1000, Config{WaitTimeout: 20 * time.Millisecond, OnTimeout: OnTimeoutProceed})
+
+	// WHEN
+	failed := failing.Acquire(context.Background())
+	proceeded := proceeding.Acquire(context.Background())
+
+	// THEN
+	require.Equal(t, ResultTimedOutFailed, failed.Result)
+	require.False(t, failed.Proceed())
+	require.Equal(t, ResultTimedOutProceeded, proceeded.Result)
+	require.True(t, proceeded.Proceed())
+	require.Empty(t, proceeded.Lease.Token)
+}
+
+func TestLockServiceIfRejected(t *testing.T) {
+	// GIVEN
+	attempts := 0
+	lockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("pool 'missing' is not defined"))
+	}))
+	t.Cleanup(lockServer.Close)
+	host, port, _ := net.SplitHostPort(lockServer.Listener.Addr().String())
+	conf := Config{LockHost: host, Period: 5 * time.Millisecond, Timeout: time.Second}
+	conf.LockPort, _ = strconv.Atoi(port)
+	client, err := NewLockClient(conf)
+	require.NoError(t, err)
+
+	// WHEN
+	outcome := NewLockService(conf, client, nil).Acquire(context.Background())
+
+	// THEN
+	require.Equal(t, ResultRejected, outcome.Result)
+	require.False(t, outcome.Proceed())
+	require.Equal(t, 1, attempts)
+	require.Equal(t, []DenialCount{{"lock request is rejected: pool 'missing' is not defined", 1}}, outcome.Denials)
+}
+
+func TestLockServiceIfInterrupted(t *testing.T) {
+	// GIVEN
+	lockService := newTestLockService(t, 1000, Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// WHEN
+	outcome := lockService.Acquire(ctx)
+
+	// THEN
+	require.Equal(t, ResultInterrupted, outcome.Result)
+	require.Positive(t, outcome.Wait)
+}
@@ -0,0 +1,101 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/init/client"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result of waiting for the lock.
+type Result string
+
+const (
+	ResultAcquired          Result = "acquired"
+	ResultTimedOutProceeded Result = "timed out, proceeded"
+	ResultTimedOutFailed    Result = "timed out, failed"
+	ResultInterrupted       Result = "interrupted"
+	ResultRejected          Result = "rejected"
+)
+
+// Kubernetes truncates termination messages to 4096 bytes, so only the first distinct denials are kept.
+const (
+	maxDenials        = 10
+	maxTerminationLog = 4096
+)
+
+// Outcome summarizes waiting for the lock.
+type Outcome struct {
+	Result   Result
+	Lease    Lease
+	Wait     time.Duration
+	Attempts int
+	Denials  []DenialCount // Distinct denial reasons in order of occurrence
+
+	started time.Time
+}
+
+// DenialCount is the reason the lock was denied, with the number of attempts denied for it.
+type DenialCount struct {
+	Reason string
+	Count  int
+}
+
+// Proceed tells whether the app may start.
+func (o Outcome) Proceed() bool {
+	return o.Result == ResultAcquired || o.Result == ResultTimedOutProceeded
+}
+
+func (o *Outcome) deny(reason string) {
+	for i := range o.Denials {
+		if o.Denials[i].Reason == reason {
+			o.Denials[i].Count++
+			return
+		}
+	}
+	if len(o.Denials) < maxDenials {
+		o.Denials = append(o.Denials, DenialCount{reason, 1})
+	}
+}
+
+func (o Outcome) finish(result Result, lease Lease) Outcome {
+	o.Result = result
+	o.Lease = lease
+	o.Wait = time.Since(o.started).Round(time.Millisecond)
+	return o
+}
+
+// Message describes the outcome for the termination log.
+func (o Outcome) Message() string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "result: %s\nwait: %s\nattempts: %d\n", o.Result, o.Wait, o.Attempts)
+	if o.Lease.Token != "" {
+		fmt.Fprintf(&message, "lease-token: %s\n", o.Lease.Token)
+	}
+	if len(o.Denials) > 0 {
+		message.WriteString("denials:\n")
+		for _, denial := range o.Denials {
+			fmt.Fprintf(&message, "- %dx %s\n", denial.Count, denial.Reason)
+		}
+	}
+	if message.Len() > maxTerminationLog {
+		return message.String()[:maxTerminationLog]
+	}
+	return message.String()
+}
@@ -0,0 +1,157 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package service
+
+import (
+	"context"
+	"errors"
+	. "flakybit.net/psl/init/client"
+	. "flakybit.net/psl/init/config"
+	"fmt"
+	"io"
+	log "log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// SidecarService runs as native sidecar: it acquires the lock, holds the lease until the app is ready
+// or the pod terminates, and keeps running, since Kubernetes restarts exited sidecars.
+type SidecarService struct {
+	conf        Config
+	client      *LockClient
+	lockService *LockService
+	appClient   *http.Client
+	proceed     atomic.Bool // Whether the app may start, the lock is acquired or the wait timed out
+}
+
+func NewSidecarService(conf Config, client *LockClient, lockService *LockService) *SidecarService {
+	service := &SidecarService{
+		conf:        conf,
+		client:      client,
+		lockService: lockService,
+		appClient:   &http.Client{Timeout: conf.Timeout},
+	}
+	log.Info("configured sidecar service", log.String("readiness-url", conf.Sidecar.ReadinessUrl))
+	return service
+}
+
+// Run acquires the lock and holds it, it returns the outcome once the context is done,
+// or right away if the app may not start.
+func (s *SidecarService) Run(ctx context.Context) Outcome {
+	go s.serveProbe(ctx)
+
+	outcome := s.lockService.Acquire(ctx)
+	s.lockService.Report(outcome)
+	if !outcome.Proceed() {
+		return outcome
+	}
+	s.proceed.Store(true)
+	if outcome.Result == ResultAcquired {
+		s.hold(ctx, outcome.Lease)
+	}
+	<-ctx.Done()
+	return outcome
+}
+
+// hold heartbeats the lease until the app is ready or the context is done, then releases it.
+func (s *SidecarService) hold(ctx context.Context, lease Lease) {
+	if lease.Token == "" {
+		log.Info("lock has no lease to hold")
+		return
+	}
+	heartbeatPeriod := lease.Duration / 3
+	if heartbeatPeriod <= 0 {
+		heartbeatPeriod = s.conf.Period
+	}
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+	readiness := time.NewTicker(s.conf.Sidecar.ReadinessPeriod)
+	defer readiness.Stop()
+
+	for {
+		select {
+		case <-heartbeat.C:
+			err := s.client.Heartbeat(ctx, lease.Token)
+			if errors.Is(err, ErrLeaseLost) {
+				log.Warn("lease is lost, app is not ready in lease duration")
+				return
+			}
+			if err != nil {
+				log.ErrorContext(ctx, "failed to heartbeat lease", log.Any("error", err))
+			}
+		case <-readiness.C:
+			if s.isAppReady(ctx) {
+				log.Info("app is ready, releasing lease")
+				s.release(ctx, lease)
+				return
+			}
+		case <-ctx.Done():
+			log.Info("pod is terminating, releasing lease")
+			s.release(ctx, lease)
+			return
+		}
+	}
+}
+
+func (s *SidecarService) release(ctx context.Context, lease Lease) {
+	// the context may be done already on termination
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.conf.Timeout)
+	defer cancel()
+	err := s.client.Release(releaseCtx, lease.Token)
+	if err != nil && !errors.Is(err, ErrLeaseLost) {
+		log.Error("failed to release lease, it expires after its duration", log.Any("error", err))
+	}
+}
+
+func (s *SidecarService) isAppReady(ctx context.Context) bool {
+	request, err := http.NewRequestWithContext(ctx, "GET", s.conf.Sidecar.ReadinessUrl, nil)
+	if err != nil {
+		log.Error("invalid app readiness URL", log.Any("error", err))
+		return false
+	}
+	response, err := s.appClient.Do(request)
+	if err != nil {
+		log.Debug("app is not ready", log.Any("error", err))
+		return false
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+	return response.StatusCode >= 200 && response.StatusCode < 300
+}
+
+// serveProbe serves startup probe of the sidecar, app containers start once it succeeds.
+func (s *SidecarService) serveProbe(ctx context.Context) {
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", s.conf.Sidecar.ProbePort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.proceed.Load() {
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to serve startup probe", log.Any("error", err))
+	}
+}
@@ -0,0 +1,110 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/init/client"
+	. "flakybit.net/psl/init/config"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubLock grants the lock with 30ms lease and records lease requests.
+type stubLock struct {
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (l *stubLock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if r.Method == http.MethodPost {
+		l.requests = append(l.requests, r.URL.Path+" "+r.FormValue("token"))
+		return
+	}
+	w.Header().Set("PSL-Lease-Token", "lease-token")
+	w.Header().Set("PSL-Lease-Duration", "30ms")
+}
+
+func (l *stubLock) leaseRequests() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.requests...)
+}
+
+func newTestSidecar(t *testing.T, lock *stubLock, readinessUrl string) *SidecarService {
+	lockServer := httptest.NewServer(lock)
+	t.Cleanup(lockServer.Close)
+	host, port, _ := net.SplitHostPort(lockServer.Listener.Addr().String())
+	lockPort, _ := strconv.Atoi(port)
+	conf := Config{
+		LockHost: host,
+		LockPort: lockPort,
+		Period:   10 * time.Millisecond,
+		Timeout:  time.Second,
+		Sidecar:  SidecarConfig{ReadinessUrl: readinessUrl, ReadinessPeriod: 10 * time.Millisecond},
+	}
+	client, err := NewLockClient(conf)
+	require.NoError(t, err)
+	return NewSidecarService(conf, client, NewLockService(conf, client, nil))
+}
+
+func TestSidecarReleasesOnceAppReady(t *testing.T) {
+	// GIVEN
+	ready := atomic.Bool{}
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer app.Close()
+	lock := &stubLock{}
+	sidecar := newTestSidecar(t, lock, app.URL)
+	lease := Lease{Token: "lease-token", Duration: 30 * time.Millisecond}
+	time.AfterFunc(50*time.Millisecond, func() { ready.Store(true) })
+
+	// WHEN
+	sidecar.hold(context.Background(), lease)
+
+	// THEN
+	requests := lock.leaseRequests()
+	require.Contains(t, requests, "/heartbeat lease-token")
+	require.Equal(t, "/release lease-token", requests[len(requests)-1])
+}
+
+func TestSidecarReleasesOnTermination(t *testing.T) {
+	// GIVEN
+	lock := &stubLock{}
+	sidecar := newTestSidecar(t, lock, "http://127.0.0.1:1/never-ready")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// WHEN
+	outcome := sidecar.Run(ctx)
+
+	// THEN
+	require.True(t, sidecar.proceed.Load())
+	require.Equal(t, ResultAcquired, outcome.Result)
+	require.Equal(t, "/release lease-token", lock.leaseRequests()[len(lock.leaseRequests())-1])
+}
@@ -40,13 +40,23 @@ package client
 
 import (
 	"context"
+	"errors"
 	. "flakybit.net/psl/k8s-health/config"
+	"fmt"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/retry"
 	metrics "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -65,83 +75,259 @@ var defaultRetriable = func(error) bool {
 	return true
 }
 
+var errNotWatching = errors.New("informers are not started, call WatchDaemonSets first")
+
 type K8sClient struct {
-	k8s     kubernetes.Clientset
-	metrics metricsv.Clientset
+	k8s           *kubernetes.Clientset
+	metrics       *metricsv.Clientset
+	dsInformers   informers.SharedInformerFactory
+	podInformers  informers.SharedInformerFactory
+	nodeInformers informers.SharedInformerFactory
+	dsLister      appslisters.DaemonSetLister
+	podLister     corelisters.PodLister
 }
 
-func NewK8sClient(conf Config) *K8sClient {
-	k8sConfig := getK8sConfig(conf)
-	k8sClient := *kubernetes.NewForConfigOrDie(k8sConfig)
-	metricsClient := *metricsv.NewForConfigOrDie(k8sConfig)
-	client := &K8sClient{k8sClient, metricsClient}
+func NewK8sClient(conf Config) (*K8sClient, error) {
+	k8sConfig, err := getK8sConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	k8sClient, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, err
+	}
+	metricsClient, err := metricsv.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, err
+	}
+	dsInformers := informers.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		informers.WithNamespace(conf.DaemonSetHC.Namespace))
+	podInformers := informers.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		informers.WithTweakListOptions(func(opt *meta.ListOptions) {
+			opt.FieldSelector = "spec.nodeName=" + conf.NodeName
+		}))
+	nodeInformers := informers.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		informers.WithTweakListOptions(func(opt *meta.ListOptions) {
+			opt.FieldSelector = "metadata.name=" + conf.NodeName
+		}))
+	client := &K8sClient{k8sClient, metricsClient, dsInformers, podInformers, nodeInformers, nil, nil}
 	log.Info("configured K8s client")
-	return client
+	return client, nil
 }
 
-func (c *K8sClient) GetNodeInfo(ctx context.Context, nodeName string) *core.Node {
+// WatchDaemonSets starts DaemonSet and node Pod informers, waits for their caches to sync
+// and calls onChange on every subsequent add, update or delete event.
+func (c *K8sClient) WatchDaemonSets(ctx context.Context, onChange func()) error {
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { onChange() },
+		UpdateFunc: func(any, any) { onChange() },
+		DeleteFunc: func(any) { onChange() },
+	}
+
+	dsInformer := c.dsInformers.Apps().V1().DaemonSets()
+	if _, err := dsInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+	podInformer := c.podInformers.Core().V1().Pods()
+	if _, err := podInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+
+	c.dsInformers.Start(ctx.Done())
+	c.podInformers.Start(ctx.Done())
+	log.Info("waiting for DaemonSet and Pod informers to sync")
+	if !cache.WaitForCacheSync(ctx.Done(), dsInformer.Informer().HasSynced, podInformer.Informer().HasSynced) {
+		return errors.New("failed to sync DaemonSet and Pod informers")
+	}
+
+	c.dsLister = dsInformer.Lister()
+	c.podLister = podInformer.Lister()
+	log.Info("DaemonSet and Pod informers synced")
+	return nil
+}
+
+// WatchNode starts the configured node informer, waits for its cache to sync
+// and calls onChange with the node on every add or update event, and with nil once the node is deleted.
+func (c *K8sClient) WatchNode(ctx context.Context, onChange func(node *core.Node)) error {
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { onChange(obj.(*core.Node)) },
+		UpdateFunc: func(_, obj any) { onChange(obj.(*core.Node)) },
+		DeleteFunc: func(any) { onChange(nil) },
+	}
+
+	nodeInformer := c.nodeInformers.Core().V1().Nodes().Informer()
+	if _, err := nodeInformer.AddEventHandler(handler); err != nil {
+		return err
+	}
+
+	c.nodeInformers.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), nodeInformer.HasSynced) {
+		return errors.New("failed to sync Node informer")
+	}
+	log.Debug("Node informer synced")
+	return nil
+}
+
+// Clientset returns the underlying K8s client, like for TokenReview of callers.
+func (c *K8sClient) Clientset() kubernetes.Interface {
+	return c.k8s
+}
+
+func (c *K8sClient) GetNodeInfo(ctx context.Context, nodeName string) (*core.Node, error) {
 	var node *core.Node
-	retryOnError(func() error {
+	err := retryOnError(func() error {
 		var err error
 		node, err = c.k8s.CoreV1().Nodes().Get(ctx, nodeName, meta.GetOptions{})
 		return err
 	})
-	return node
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node '%s': %w", nodeName, err)
+	}
+	return node, nil
 }
 
-func (c *K8sClient) GetNodeMetrics(ctx context.Context, nodeName string) *metrics.NodeMetrics {
+func (c *K8sClient) GetNodeMetrics(ctx context.Context, nodeName string) (*metrics.NodeMetrics, error) {
 	var nodeMetrics *metrics.NodeMetrics
-	retryOnError(func() error {
+	err := retryOnError(func() error {
 		var err error
 		nodeMetrics, err = c.metrics.MetricsV1beta1().NodeMetricses().Get(ctx, nodeName, meta.GetOptions{})
 		return err
 	})
-	return nodeMetrics
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics of node '%s': %w", nodeName, err)
+	}
+	return nodeMetrics, nil
+}
+
+func (c *K8sClient) GetDeployment(ctx context.Context, namespace, name string) (*apps.Deployment, error) {
+	var deployment *apps.Deployment
+	err := retryOnError(func() error {
+		var err error
+		deployment, err = c.k8s.AppsV1().Deployments(namespace).Get(ctx, name, meta.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment '%s/%s': %w", namespace, name, err)
+	}
+	return deployment, nil
 }
 
-func (c *K8sClient) GetDaemonSets(ctx context.Context, namespace string) []apps.DaemonSet {
-	var daemonSets *apps.DaemonSetList
-	retryOnError(func() error {
+func (c *K8sClient) GetStatefulSet(ctx context.Context, namespace, name string) (*apps.StatefulSet, error) {
+	var statefulSet *apps.StatefulSet
+	err := retryOnError(func() error {
 		var err error
-		daemonSets, err = c.k8s.AppsV1().DaemonSets(namespace).List(ctx, meta.ListOptions{})
+		statefulSet, err = c.k8s.AppsV1().StatefulSets(namespace).Get(ctx, name, meta.GetOptions{})
 		return err
 	})
-	return daemonSets.Items
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset '%s/%s': %w", namespace, name, err)
+	}
+	return statefulSet, nil
 }
 
-func (c *K8sClient) GetNodePods(ctx context.Context, nodeName string) []core.Pod {
+// GetServiceEndpoints returns EndpointSlices of the Service.
+func (c *K8sClient) GetServiceEndpoints(ctx context.Context, namespace, service string) ([]discovery.EndpointSlice, error) {
 	opt := meta.ListOptions{}
-	opt.FieldSelector = "spec.nodeName=" + nodeName
+	opt.LabelSelector = discovery.LabelServiceName + "=" + service
 
-	var pods *core.PodList
-	retryOnError(func() error {
+	var slices *discovery.EndpointSliceList
+	err := retryOnError(func() error {
 		var err error
-		pods, err = c.k8s.CoreV1().Pods("").List(ctx, opt)
+		slices, err = c.k8s.DiscoveryV1().EndpointSlices(namespace).List(ctx, opt)
 		return err
 	})
-	return pods.Items
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints of service '%s/%s': %w", namespace, service, err)
+	}
+	return slices.Items, nil
 }
 
-func getK8sConfig(appConfig Config) *rest.Config {
-	if appConfig.K8sApiUrl != "" {
-		log.Info("using out-of-cluster K8s client config", log.String("k8s-url", appConfig.K8sApiUrl))
-		config := rest.Config{}
-		config.Host = appConfig.K8sApiUrl
-		config.Insecure = true
-		return &config
+// GetDaemonSets returns DaemonSets of the configured namespace from the informer cache.
+func (c *K8sClient) GetDaemonSets() ([]apps.DaemonSet, error) {
+	if c.dsLister == nil {
+		return nil, errNotWatching
 	}
+	cached, err := c.dsLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	daemonSets := make([]apps.DaemonSet, 0, len(cached))
+	for _, ds := range cached {
+		daemonSets = append(daemonSets, *ds)
+	}
+	return daemonSets, nil
+}
 
-	log.Debug("using in-cluster K8s client config")
-	config, err := rest.InClusterConfig()
+// GetNodePods returns Pods scheduled on the configured node from the informer cache.
+func (c *K8sClient) GetNodePods() ([]core.Pod, error) {
+	if c.podLister == nil {
+		return nil, errNotWatching
+	}
+	cached, err := c.podLister.List(labels.Everything())
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	pods := make([]core.Pod, 0, len(cached))
+	for _, pod := range cached {
+		pods = append(pods, *pod)
 	}
-	return config
+	return pods, nil
 }
 
-func retryOnError(fn func() error) {
-	err := retry.OnError(defaultRetry, defaultRetriable, fn)
+func getK8sConfig(appConfig Config) (*rest.Config, error) {
+	config, err := loadK8sConfig(appConfig.K8s)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	if appConfig.K8s.TokenFile != "" {
+		config.BearerToken = ""
+		config.BearerTokenFile = appConfig.K8s.TokenFile
 	}
+	if appConfig.K8s.CaFile != "" {
+		config.TLSClientConfig.CAData = nil
+		config.TLSClientConfig.CAFile = appConfig.K8s.CaFile
+	}
+	if appConfig.K8s.Insecure {
+		log.Warn("K8s API server certificate verification is disabled")
+		config.TLSClientConfig.CAData = nil
+		config.TLSClientConfig.CAFile = ""
+		config.TLSClientConfig.Insecure = true
+	}
+	config.QPS = appConfig.K8s.Qps
+	config.Burst = appConfig.K8s.Burst
+	return config, nil
+}
+
+// loadK8sConfig loads kubeconfig from the configured path, or from KUBECONFIG env var or ~/.kube/config,
+// and falls back to the configured API URL or in-cluster config if there is none.
+func loadK8sConfig(conf K8sConfig) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = conf.Kubeconfig
+	kubeconfig, err := rules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	if conf.Kubeconfig == "" && conf.Context == "" && clientcmdapi.IsConfigEmpty(kubeconfig) {
+		if conf.ApiUrl != "" {
+			log.Info("using out-of-cluster K8s client config", log.String("k8s-url", conf.ApiUrl))
+			config := rest.Config{}
+			config.Host = conf.ApiUrl
+			return &config, nil
+		}
+		log.Debug("using in-cluster K8s client config")
+		return rest.InClusterConfig()
+	}
+
+	log.Info("using kubeconfig K8s client config",
+		log.Any("kubeconfig", rules.GetLoadingPrecedence()),
+		log.String("context", conf.Context))
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: conf.Context}
+	overrides.ClusterInfo.Server = conf.ApiUrl
+	return clientcmd.NewDefaultClientConfig(*kubeconfig, overrides).ClientConfig()
+}
+
+func retryOnError(fn func() error) error {
+	return retry.OnError(defaultRetry, defaultRetriable, fn)
 }
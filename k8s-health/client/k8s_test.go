@@ -0,0 +1,103 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com:6443
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+current-context: dev
+`
+
+// withKubeconfigEnv points KUBECONFIG env var to the kubeconfig, or to a missing file if it is empty,
+// and HOME to an empty directory, not to load ~/.kube/config.
+func withKubeconfigEnv(t *testing.T, kubeconfig string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeconfig")
+	if kubeconfig != "" {
+		require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0o600))
+	}
+	t.Setenv("KUBECONFIG", path)
+	t.Setenv("HOME", dir)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+}
+
+func TestLoadK8sConfigFromKubeconfigEnv(t *testing.T) {
+	// GIVEN
+	withKubeconfigEnv(t, testKubeconfig)
+
+	// WHEN
+	config, err := loadK8sConfig(K8sConfig{})
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, "https://dev.example.com:6443", config.Host)
+}
+
+func TestLoadK8sConfigOverridesServer(t *testing.T) {
+	// GIVEN
+	withKubeconfigEnv(t, testKubeconfig)
+
+	// WHEN
+	config, err := loadK8sConfig(K8sConfig{ApiUrl: "http://localhost:8001"})
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8001", config.Host)
+}
+
+func TestLoadK8sConfigIfNoKubeconfig(t *testing.T) {
+	// GIVEN
+	withKubeconfigEnv(t, "")
+
+	// WHEN
+	urlConfig, urlErr := loadK8sConfig(K8sConfig{ApiUrl: "http://localhost:8001"})
+	_, inClusterErr := loadK8sConfig(K8sConfig{})
+
+	// THEN
+	require.NoError(t, urlErr)
+	require.Equal(t, "http://localhost:8001", urlConfig.Host)
+	require.ErrorIs(t, inClusterErr, rest.ErrNotInCluster)
+}
+
+func TestLoadK8sConfigIfKubeconfigMissing(t *testing.T) {
+	// GIVEN
+	withKubeconfigEnv(t, "")
+
+	// WHEN
+	_, err := loadK8sConfig(K8sConfig{Kubeconfig: filepath.Join(t.TempDir(), "missing")})
+
+	// THEN
+	require.ErrorContains(t, err, "failed to load kubeconfig")
+}
@@ -19,8 +19,13 @@ package config
 import (
 	"context"
 	"errors"
+	. "flakybit.net/psl/common/server"
+	"fmt"
 	"github.com/sethvargo/go-envconfig"
+	"k8s.io/apimachinery/pkg/labels"
 	log "log/slog"
+	"path"
+	"slices"
 	"time"
 )
 
@@ -28,25 +33,94 @@ type Config struct {
 	BindHost    string                     `env:"PSL_BIND_HOST"`               // Address to bind
 	BindPort    int                        `env:"PSL_BIND_PORT, default=8080"` // Port to bind
 	NodeName    string                     `env:"PSL_NODE_NAME, required"`     // K8s node name which the current app instance runs on
-	K8sApiUrl   string                     `env:"PSL_K8S_API_URL"`             // K8s API URL, for out-of-cluster usage only
+	K8s         K8sConfig                  `env:", prefix=PSL_K8S_"`
+	StaleTtl    time.Duration              `env:"PSL_STALE_TTL, default=0s"` // How long the last known check result is trusted when K8s API fails
+	Tls         TlsConfig                  `env:", prefix=PSL_TLS_"`
+	Auth        AuthConfig                 `env:", prefix=PSL_AUTH_"`
+	Events      bool                       `env:"PSL_EVENTS_ENABLED, default=false"` // Record K8s Events on the node when a DaemonSet blocks pod startups
 	DaemonSetHC DaemonSetHealthCheckConfig `env:", prefix=PSL_HC_DAEMONSET_"`
 	NodeLoadHC  NodeLoadHealthCheckConfig  `env:", prefix=PSL_HC_NODELOAD_"`
+	WorkloadHC  WorkloadHealthCheckConfig  `env:", prefix=PSL_HC_WORKLOAD_"`
+	NodeHC      NodeHealthCheckConfig      `env:", prefix=PSL_HC_NODE_"`
+}
+
+type K8sConfig struct {
+	ApiUrl     string  `env:"API_URL"`                 // K8s API URL, for out-of-cluster usage only
+	Kubeconfig string  `env:"KUBECONFIG"`              // Path to kubeconfig file, for out-of-cluster usage only
+	Context    string  `env:"CONTEXT"`                 // Kubeconfig context to use, blank for current context
+	TokenFile  string  `env:"TOKEN_FILE"`              // Path to bearer token file
+	CaFile     string  `env:"CA_FILE"`                 // Path to CA bundle to verify K8s API server certificate
+	Insecure   bool    `env:"INSECURE, default=false"` // Skip verification of K8s API server certificate
+	Qps        float32 `env:"QPS, default=5"`          // Maximum queries per second to K8s API
+	Burst      int     `env:"BURST, default=10"`       // Maximum burst of queries to K8s API
 }
 
 type DaemonSetHealthCheckConfig struct {
-	Enabled      bool              `env:"ENABLED, default=true"`
-	Namespace    string            `env:"NAMESPACE"`                   // K8s Namespace to check DaemonSets in, blank for all namespaces
-	HostNetwork  bool              `env:"HOST_NETWORK, default=false"` // Host network DaemonSets only
-	Include      map[string]string `env:"INCLUDE_LABELS"`              // Include DaemonSet labels, "label1:value1,label2:value2"
-	Exclude      map[string]string `env:"EXCLUDE_LABELS"`              // Exclude DaemonSet labels, "label1:value1,label2:value2"
-	PeriodOnFail time.Duration     `env:"PERIOD_FAIL, default=10s"`    // Period of health checks if previous failed
-	PeriodOnPass time.Duration     `env:"PERIOD_PASS, default=60s"`    // Period of health checks if previous succeeded
+	Enabled         bool              `env:"ENABLED, default=true"`
+	Mode            string            `env:"MODE, default=required"`      // Whether failed check makes node unhealthy: "required" or "advisory"
+	Namespace       string            `env:"NAMESPACE"`                   // K8s Namespace to check DaemonSets in, blank for all namespaces
+	HostNetwork     bool              `env:"HOST_NETWORK, default=false"` // Host network DaemonSets only
+	Include         map[string]string `env:"INCLUDE_LABELS"`              // Include DaemonSet labels, "label1:value1,label2:value2", deprecated
+	Exclude         map[string]string `env:"EXCLUDE_LABELS"`              // Exclude DaemonSet labels, "label1:value1,label2:value2", deprecated
+	IncludeSelector string            `env:"INCLUDE_SELECTOR"`            // Include DaemonSets matching label selector, "app in (a,b),!debug"
+	ExcludeSelector string            `env:"EXCLUDE_SELECTOR"`            // Exclude DaemonSets matching label selector, "app in (a,b),!debug"
+	IncludeNames    []string          `env:"INCLUDE_NAMES"`               // Include DaemonSets matching name patterns, "kube-system/*,cni-*"
+	ExcludeNames    []string          `env:"EXCLUDE_NAMES"`               // Exclude DaemonSets matching name patterns, "kube-system/*-debug"
+	Strict          bool              `env:"STRICT, default=false"`       // Always check DaemonSet pods on the node are ready and up-to-date
+	PeriodOnFail    time.Duration     `env:"PERIOD_FAIL, default=10s"`    // Period of health checks if previous failed
+	PeriodOnPass    time.Duration     `env:"PERIOD_PASS, default=60s"`    // Period of health checks if previous succeeded
 }
 
 type NodeLoadHealthCheckConfig struct {
+	Enabled              bool          `env:"ENABLED, default=false"`
+	Mode                 string        `env:"MODE, default=required"`            // Whether failed check makes node unhealthy: "required" or "advisory"
+	CpuThreshold         int           `env:"CPU_THRESHOLD, default=80"`         // Node CPU utilisation in percent above which it is treated as unhealthy
+	CpuRecoveryThreshold int           `env:"CPU_RECOVERY_THRESHOLD, default=0"` // Node CPU utilisation in percent below which it is treated as healthy again, 0 to use CPU_THRESHOLD
+	UnhealthyAfter       time.Duration `env:"UNHEALTHY_AFTER, default=0s"`       // Time CPU utilisation must stay above threshold before node becomes unhealthy
+	HealthyAfter         time.Duration `env:"HEALTHY_AFTER, default=0s"`         // Time CPU utilisation must stay below recovery threshold before node becomes healthy
+	Smoothing            string        `env:"SMOOTHING, default=none"`           // CPU utilisation smoothing: "none", "ewma" or "window"
+	EwmaAlpha            float64       `env:"EWMA_ALPHA, default=0.3"`           // Weight of the latest sample for "ewma" smoothing, (0, 1]
+	WindowSize           int           `env:"WINDOW_SIZE, default=6"`            // Number of samples to average for "window" smoothing
+	CpuBase              string        `env:"CPU_BASE, default=allocatable"`     // Node CPU amount utilisation is relative to: "allocatable" or "capacity"
+	Period               time.Duration `env:"PERIOD, default=10s"`               // Period of health checks
+}
+
+type WorkloadHealthCheckConfig struct {
 	Enabled      bool          `env:"ENABLED, default=false"`
-	CpuThreshold int           `env:"CPU_THRESHOLD, default=80"` // Node CPU utilisation in percent above which it is treated as unhealthy
-	Period       time.Duration `env:"PERIOD, default=10s"`       // Period of health checks
+	Mode         string        `env:"MODE, default=required"`   // Whether failed check makes node unhealthy: "required" or "advisory"
+	Targets      []string      `env:"TARGETS"`                  // Workloads to check, "deployment/namespace/name:2,endpoints/namespace/name"
+	PeriodOnFail time.Duration `env:"PERIOD_FAIL, default=10s"` // Period of health checks if previous failed
+	PeriodOnPass time.Duration `env:"PERIOD_PASS, default=60s"` // Period of health checks if previous succeeded
+}
+
+type NodeHealthCheckConfig struct {
+	Enabled bool     `env:"ENABLED, default=false"`
+	Mode    string   `env:"MODE, default=required"`                                                                                                                                            // Whether failed check makes node unhealthy: "required" or "advisory"
+	Taints  []string `env:"TAINTS, default=node.kubernetes.io/not-ready,node.kubernetes.io/unreachable,node.kubernetes.io/network-unavailable,node.cloudprovider.kubernetes.io/uninitialized"` // Node taint keys which must be gone
+}
+
+const (
+	ModeRequired = "required"
+	ModeAdvisory = "advisory"
+)
+
+const (
+	CpuBaseAllocatable = "allocatable"
+	CpuBaseCapacity    = "capacity"
+)
+
+const (
+	SmoothingNone   = "none"
+	SmoothingEwma   = "ewma"
+	SmoothingWindow = "window"
+)
+
+// RecoveryThreshold returns CPU utilisation in percent below which an unhealthy node is treated as healthy again.
+func (c NodeLoadHealthCheckConfig) RecoveryThreshold() int {
+	if c.CpuRecoveryThreshold == 0 {
+		return c.CpuThreshold
+	}
+	return c.CpuRecoveryThreshold
 }
 
 func NewConfig(ctx context.Context) (Config, error) {
@@ -64,9 +138,36 @@ func NewConfig(ctx context.Context) (Config, error) {
 }
 
 func (c *Config) validate() error {
-	var dsIncludeExcludeError error
-	if len(c.DaemonSetHC.Include) > 0 && len(c.DaemonSetHC.Exclude) > 0 {
-		dsIncludeExcludeError = errors.New("cannot specify both Included and Excluded DaemonSet ")
+	var staleTtlError error
+	if c.StaleTtl < 0 {
+		staleTtlError = errors.New("stale result TTL is lesser than 0")
+	}
+	var k8sInsecureError error
+	if c.K8s.Insecure && c.K8s.CaFile != "" {
+		k8sInsecureError = errors.New("cannot specify both K8s API CA bundle and insecure connection")
+	}
+	var k8sRateError error
+	if c.K8s.Qps < 0 || c.K8s.Burst < 0 {
+		k8sRateError = errors.New("K8s client QPS or burst is lesser than 0")
+	}
+	var modeError error
+	for _, mode := range []string{c.DaemonSetHC.Mode, c.NodeLoadHC.Mode, c.WorkloadHC.Mode, c.NodeHC.Mode} {
+		if mode != ModeRequired && mode != ModeAdvisory {
+			modeError = errors.Join(modeError, fmt.Errorf("unknown health check mode: '%s'", mode))
+		}
+	}
+	var dsSelectorError error
+	if _, err := labels.Parse(c.DaemonSetHC.IncludeSelector); err != nil {
+		dsSelectorError = fmt.Errorf("include selector of DaemonSet check is malformed: %w", err)
+	}
+	if _, err := labels.Parse(c.DaemonSetHC.ExcludeSelector); err != nil {
+		dsSelectorError = errors.Join(dsSelectorError, fmt.Errorf("exclude selector of DaemonSet check is malformed: %w", err))
+	}
+	var dsNamesError error
+	for _, pattern := range slices.Concat(c.DaemonSetHC.IncludeNames, c.DaemonSetHC.ExcludeNames) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			dsNamesError = errors.Join(dsNamesError, fmt.Errorf("name pattern of DaemonSet check is malformed: '%s'", pattern))
+		}
 	}
 	var dsPeriodPassError error
 	if c.DaemonSetHC.PeriodOnPass < 0 {
@@ -80,9 +181,50 @@ func (c *Config) validate() error {
 	if c.NodeLoadHC.CpuThreshold < 0 || c.NodeLoadHC.CpuThreshold > 100 {
 		nlThresholdError = errors.New("cpu threshold of node load check is out of interval [0, 100]")
 	}
+	var nlRecoveryThresholdError error
+	if c.NodeLoadHC.CpuRecoveryThreshold < 0 || c.NodeLoadHC.CpuRecoveryThreshold > c.NodeLoadHC.CpuThreshold {
+		nlRecoveryThresholdError = errors.New("cpu recovery threshold of node load check is out of interval [0, cpu threshold]")
+	}
+	var nlDwellError error
+	if c.NodeLoadHC.UnhealthyAfter < 0 || c.NodeLoadHC.HealthyAfter < 0 {
+		nlDwellError = errors.New("dwell time of node load check is lesser than 0")
+	}
+	var nlSmoothingError error
+	switch c.NodeLoadHC.Smoothing {
+	case SmoothingNone:
+	case SmoothingEwma:
+		if c.NodeLoadHC.EwmaAlpha <= 0 || c.NodeLoadHC.EwmaAlpha > 1 {
+			nlSmoothingError = errors.New("ewma alpha of node load check is out of interval (0, 1]")
+		}
+	case SmoothingWindow:
+		if c.NodeLoadHC.WindowSize < 1 {
+			nlSmoothingError = errors.New("window size of node load check is lesser than 1")
+		}
+	default:
+		nlSmoothingError = fmt.Errorf("unknown smoothing of node load check: '%s'", c.NodeLoadHC.Smoothing)
+	}
+	var nlCpuBaseError error
+	if c.NodeLoadHC.CpuBase != CpuBaseAllocatable && c.NodeLoadHC.CpuBase != CpuBaseCapacity {
+		nlCpuBaseError = fmt.Errorf("unknown CPU base of node load check: '%s'", c.NodeLoadHC.CpuBase)
+	}
 	var nlPeriodError error
 	if c.NodeLoadHC.Period < 0 {
 		nlPeriodError = errors.New("period of node load check is lesser than 0")
 	}
-	return errors.Join(dsIncludeExcludeError, dsPeriodPassError, dsPeriodFailError, nlThresholdError, nlPeriodError)
+	var wlTargetsError error
+	if c.WorkloadHC.Enabled && len(c.WorkloadHC.Targets) == 0 {
+		wlTargetsError = errors.New("workload health check is enabled, but target list is empty")
+	}
+	for _, target := range c.WorkloadHC.Targets {
+		if _, err := ParseWorkloadRef(target); err != nil {
+			wlTargetsError = errors.Join(wlTargetsError, err)
+		}
+	}
+	var wlPeriodError error
+	if c.WorkloadHC.PeriodOnPass < 0 || c.WorkloadHC.PeriodOnFail < 0 {
+		wlPeriodError = errors.New("period of workload check is lesser than 0")
+	}
+	return errors.Join(c.Tls.Validate(), c.Auth.Validate(), staleTtlError, k8sInsecureError, k8sRateError, modeError, dsSelectorError, dsNamesError, dsPeriodPassError, dsPeriodFailError,
+		nlThresholdError, nlRecoveryThresholdError, nlDwellError, nlSmoothingError, nlCpuBaseError, nlPeriodError,
+		wlTargetsError, wlPeriodError)
 }
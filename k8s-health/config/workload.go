@@ -0,0 +1,70 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	WorkloadDeployment  = "deployment"
+	WorkloadStatefulSet = "statefulset"
+	WorkloadEndpoints   = "endpoints"
+)
+
+// AllReplicas requires all desired replicas of a workload to be ready.
+const AllReplicas = -1
+
+var workloadPattern = regexp.MustCompile(`^(\w+)/([^/:]+)/([^/:]+)(?::(\d+))?$`)
+
+// WorkloadRef refers to a cluster-level dependency and its readiness criteria.
+type WorkloadRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+	MinReady  int // Minimum ready replicas or ready Endpoints, AllReplicas for all desired replicas
+}
+
+func (w WorkloadRef) String() string {
+	return fmt.Sprintf("%s/%s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// ParseWorkloadRef parses "kind/namespace/name[:minReady]" workload reference.
+func ParseWorkloadRef(str string) (WorkloadRef, error) {
+	match := workloadPattern.FindStringSubmatch(str)
+	if match == nil {
+		return WorkloadRef{}, fmt.Errorf("workload reference malformed: '%s'", str)
+	}
+	ref := WorkloadRef{match[1], match[2], match[3], AllReplicas}
+	switch ref.Kind {
+	case WorkloadDeployment, WorkloadStatefulSet:
+	case WorkloadEndpoints:
+		ref.MinReady = 1
+	default:
+		return WorkloadRef{}, fmt.Errorf("workload kind unsupported: '%s'", str)
+	}
+	if match[4] != "" {
+		minReady, err := strconv.Atoi(match[4])
+		if err != nil {
+			return WorkloadRef{}, fmt.Errorf("workload min ready malformed: '%s'", str)
+		}
+		ref.MinReady = minReady
+	}
+	return ref, nil
+}
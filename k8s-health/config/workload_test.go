@@ -0,0 +1,76 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseWorkloadRefDeployment(t *testing.T) {
+	// GIVEN
+	expected := WorkloadRef{WorkloadDeployment, "database", "postgres", AllReplicas}
+
+	// WHEN
+	actual, err := ParseWorkloadRef("deployment/database/postgres")
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestParseWorkloadRefStatefulSetMinReady(t *testing.T) {
+	// GIVEN
+	expected := WorkloadRef{WorkloadStatefulSet, "database", "mongo", 2}
+
+	// WHEN
+	actual, err := ParseWorkloadRef("statefulset/database/mongo:2")
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestParseWorkloadRefEndpointsDefaultsToOneAddress(t *testing.T) {
+	// GIVEN
+	expected := WorkloadRef{WorkloadEndpoints, "kube-system", "kube-dns", 1}
+
+	// WHEN
+	actual, err := ParseWorkloadRef("endpoints/kube-system/kube-dns")
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestParseWorkloadRefUnsupportedKind(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseWorkloadRef("daemonset/kube-system/proxy")
+
+	// THEN
+	require.EqualError(t, err, "workload kind unsupported: 'daemonset/kube-system/proxy'")
+}
+
+func TestParseWorkloadRefMalformed(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseWorkloadRef("deployment/postgres")
+
+	// THEN
+	require.EqualError(t, err, "workload reference malformed: 'deployment/postgres'")
+}
@@ -39,6 +39,7 @@ package main
 
 import (
 	"context"
+	. "flakybit.net/psl/common/server"
 	. "flakybit.net/psl/k8s-health/client"
 	. "flakybit.net/psl/k8s-health/config"
 	. "flakybit.net/psl/k8s-health/service"
@@ -63,14 +64,31 @@ func main() {
 		panic(err)
 	}
 
-	k8sClient := NewK8sClient(conf)
+	k8sClient, err := NewK8sClient(conf)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure K8s client", log.Any("error", err))
+		panic(err)
+	}
 
-	healthCheckService := NewHealthCheckService(ctx, conf, k8sClient)
+	healthCheckService, err := NewHealthCheckService(ctx, conf, k8sClient)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure health check service", log.Any("error", err))
+		panic(err)
+	}
 	healthCheckService.Run(ctx)
 
 	controller := NewController(healthCheckService)
-	httpServer := NewHttpServer(conf, controller)
-	err = httpServer.ListenAndServe()
+	authenticator, err := NewAuthenticator(conf.Auth, k8sClient.Clientset())
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure authenticator", log.Any("error", err))
+		panic(err)
+	}
+	httpServer, err := NewHttpServer(conf, authenticator.Wrap(controller))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure http server", log.Any("error", err))
+		panic(err)
+	}
+	err = ListenAndServe(httpServer)
 	if err != nil {
 		log.ErrorContext(ctx, "failed to start http server", log.Any("error", err))
 		panic(err)
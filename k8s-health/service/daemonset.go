@@ -40,7 +40,7 @@ package service
 
 import (
 	"context"
-	. "flakybit.net/psl/common/util"
+	. "flakybit.net/psl/common/events"
 	. "flakybit.net/psl/k8s-health/client"
 	. "flakybit.net/psl/k8s-health/config"
 	"fmt"
@@ -49,38 +49,88 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// templateGenerationAnnotation holds DaemonSet template generation, which the DaemonSet controller
+// copies to templateGenerationLabel of the pods it creates.
+const (
+	templateGenerationAnnotation = "deprecated.daemonset.template.generation"
+	templateGenerationLabel      = "pod-template-generation"
 )
 
 type DaemonSetChecker struct {
-	conf       Config
-	client     *K8sClient
-	nodeLabels map[string]string
-	healthy    bool
+	conf     Config
+	client   *K8sClient
+	node     *watchedNode
+	filter   *daemonSetFilter
+	stale    *staleGuard
+	recorder *Recorder // Nil if events are disabled
+	blocking string    // DaemonSet the last event was recorded for
+	checkerState
 }
 
-func NewDaemonSetChecker(conf Config, client *K8sClient, node *core.Node) *DaemonSetChecker {
-	checker := &DaemonSetChecker{conf, client, node.Labels, false}
+func NewDaemonSetChecker(conf Config, client *K8sClient, node *core.Node) (*DaemonSetChecker, error) {
+	filter, err := newDaemonSetFilter(conf.DaemonSetHC)
+	if err != nil {
+		return nil, err
+	}
+	checker := &DaemonSetChecker{
+		conf:         conf,
+		client:       client,
+		node:         newWatchedNode(node),
+		filter:       filter,
+		stale:        newStaleGuard(conf.StaleTtl),
+		checkerState: checkerState{reason: notCheckedReason},
+	}
+	if conf.Events {
+		checker.recorder, err = NewRecorder(client.Clientset(), "psl-k8s-health")
+		if err != nil {
+			return nil, err
+		}
+	}
 	log.Info("configured DaemonSet checker")
-	return checker
+	return checker, nil
 }
 
-func (dsc *DaemonSetChecker) IsHealthy() bool {
-	if !dsc.conf.DaemonSetHC.Enabled {
-		return true
-	}
-	return dsc.healthy
+func (dsc *DaemonSetChecker) Name() string {
+	return "daemon-set"
 }
 
 func (dsc *DaemonSetChecker) Run(ctx context.Context) {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default: // check is already pending
+		}
+	}
+	err := dsc.client.WatchDaemonSets(ctx, notify)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to watch DaemonSets", log.Any("error", err))
+		return
+	}
+	err = dsc.node.watch(ctx, dsc.client, func(old, node *core.Node) {
+		// eligibility depends on node labels and taints only
+		if !equality.Semantic.DeepEqual(old.Labels, node.Labels) || !equality.Semantic.DeepEqual(old.Spec.Taints, node.Spec.Taints) {
+			log.Info("node labels or taints changed, re-checking DaemonSets")
+			notify()
+		}
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to watch node, using node info fetched at startup", log.Any("error", err))
+	}
+
 	ticker := time.NewTicker(dsc.conf.DaemonSetHC.PeriodOnFail)
 	defer ticker.Stop()
 
 	for {
-		checkStatus := dsc.check(ctx)
-		if checkStatus != dsc.healthy {
+		checkStatus, reason := dsc.evaluate(ctx)
+		if checkStatus != dsc.IsHealthy() {
 			log.Info("DaemonSet health check status changed",
-				log.Bool("old", dsc.healthy),
-				log.Bool("new", checkStatus))
+				log.Bool("old", dsc.IsHealthy()),
+				log.Bool("new", checkStatus),
+				log.String("reason", reason))
 			if checkStatus {
 				ticker.Reset(dsc.conf.DaemonSetHC.PeriodOnPass)
 			} else {
@@ -88,9 +138,11 @@ func (dsc *DaemonSetChecker) Run(ctx context.Context) {
 			}
 		}
 		log.Debug("performed DaemonSet health check", log.Bool("healthy", checkStatus))
-		dsc.healthy = checkStatus
+		dsc.set(checkStatus, reason)
 
 		select {
+		case <-changes:
+			continue
 		case <-ticker.C:
 			continue
 		case <-ctx.Done():
@@ -99,14 +151,63 @@ func (dsc *DaemonSetChecker) Run(ctx context.Context) {
 	}
 }
 
-func (dsc *DaemonSetChecker) check(ctx context.Context) bool {
-	daemonSets := dsc.client.GetDaemonSets(ctx, dsc.conf.DaemonSetHC.Namespace)
+// evaluate performs the check and falls back to the last known result if it is not stale yet.
+func (dsc *DaemonSetChecker) evaluate(ctx context.Context) (bool, string) {
+	now := time.Now()
+	blocking, err := dsc.check()
+	if err == nil {
+		dsc.stale.succeeded(now)
+		dsc.recordBlocking(ctx, blocking)
+		if blocking == nil {
+			return true, "all DaemonSets are ready"
+		}
+		return false, fmt.Sprintf("DaemonSet '%s/%s' is not ready on node", blocking.Namespace, blocking.Name)
+	}
+	if dsc.stale.trusted(now) {
+		log.WarnContext(ctx, "failed to check DaemonSets, trusting last known result",
+			log.Bool("healthy", dsc.IsHealthy()),
+			log.Any("error", err))
+		return dsc.IsHealthy(), dsc.Reason()
+	}
+	log.ErrorContext(ctx, "failed to check DaemonSets", log.Any("error", err))
+	return false, err.Error()
+}
+
+// recordBlocking records Node event once the DaemonSet starts blocking pod startups, or another one does.
+func (dsc *DaemonSetChecker) recordBlocking(ctx context.Context, blocking *apps.DaemonSet) {
+	if dsc.recorder == nil {
+		return
+	}
+	if blocking == nil {
+		dsc.blocking = ""
+		return
+	}
+	name := blocking.Namespace + "/" + blocking.Name
+	if name == dsc.blocking {
+		return
+	}
+	dsc.blocking = name
+	node := dsc.node.get()
+	dsc.recorder.Record(ctx, core.ObjectReference{Kind: "Node", APIVersion: "v1", Name: node.Name, UID: node.UID},
+		EventTypeWarning, "StartupBlockedByDaemonSet",
+		fmt.Sprintf("Pod startups on the node are blocked until DaemonSet '%s' is ready", name))
+}
+
+// check returns required DaemonSet which is not ready on the node, nil if all are ready.
+func (dsc *DaemonSetChecker) check() (*apps.DaemonSet, error) {
+	daemonSets, err := dsc.client.GetDaemonSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DaemonSets: %w", err)
+	}
 	requiredDaemonSets := dsc.getRequiredDaemonSets(daemonSets)
-	if dsc.checkDaemonSetsReady(requiredDaemonSets) {
-		return true
+	if !dsc.conf.DaemonSetHC.Strict && dsc.checkDaemonSetsReady(requiredDaemonSets) {
+		return nil, nil
 	}
-	nodePods := dsc.client.GetNodePods(ctx, dsc.conf.NodeName)
-	return dsc.checkDaemonSetsPodsAvailableOnNode(daemonSets, nodePods)
+	nodePods, err := dsc.client.GetNodePods()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node pods: %w", err)
+	}
+	return dsc.findUnavailableDaemonSet(requiredDaemonSets, nodePods), nil
 }
 
 func (dsc *DaemonSetChecker) getRequiredDaemonSets(daemonSets []apps.DaemonSet) []apps.DaemonSet {
@@ -124,18 +225,14 @@ func (dsc *DaemonSetChecker) getRequiredDaemonSets(daemonSets []apps.DaemonSet)
 
 func (dsc *DaemonSetChecker) checkRequired(ds *apps.DaemonSet) (bool, string) {
 	reason := fmt.Sprintf("'%s/%s' daemonSet Excluded from healthcheck: ", ds.Namespace, ds.Name)
-	if len(dsc.conf.DaemonSetHC.Exclude) > 0 && MapContainsAny(ds.Labels, dsc.conf.DaemonSetHC.Exclude) {
-		return false, reason + "matches exclude labels"
-	}
-	if len(dsc.conf.DaemonSetHC.Include) > 0 && !MapContainsAll(ds.Labels, dsc.conf.DaemonSetHC.Include) {
-		return false, reason + "not matches include labels"
+	if matched, mismatch := dsc.filter.match(ds); !matched {
+		return false, reason + mismatch
 	}
 	if dsc.conf.DaemonSetHC.HostNetwork && !ds.Spec.Template.Spec.HostNetwork {
 		return false, reason + "not on host network"
 	}
-	nodeSelector := ds.Spec.Template.Spec.NodeSelector
-	if len(nodeSelector) > 0 && !MapContainsAll(dsc.nodeLabels, nodeSelector) {
-		return false, reason + "not eligible for scheduling on node"
+	if eligible, ineligibility := isEligibleOnNode(ds, dsc.node.get()); !eligible {
+		return false, reason + "not eligible for scheduling on node, " + ineligibility
 	}
 	return true, fmt.Sprintf("'%s/%s' daemonSet healthcheck required", ds.Namespace, ds.Name)
 }
@@ -156,31 +253,68 @@ func (dsc *DaemonSetChecker) checkDaemonSetsReady(daemonSets []apps.DaemonSet) b
 	return true
 }
 
-func (dsc *DaemonSetChecker) checkDaemonSetsPodsAvailableOnNode(daemonSets []apps.DaemonSet, pods []core.Pod) bool {
+// findUnavailableDaemonSet returns the first DaemonSet having no available pod on the node, nil if all have.
+func (dsc *DaemonSetChecker) findUnavailableDaemonSet(daemonSets []apps.DaemonSet, pods []core.Pod) *apps.DaemonSet {
 	for _, ds := range daemonSets {
 		log.Debug("looking for pods on node", log.String("daemon-set", ds.Name))
-		pod, found := findDaemonSetPod(&ds, pods)
-		if !found {
+		dsPods := findDaemonSetPods(&ds, pods)
+		if len(dsPods) == 0 {
 			log.Info("no pod found", log.String("daemon-set", ds.Name))
-			return false
+			return &ds
 		}
-		log.Debug("pod found", log.String("daemon-set", ds.Name), log.String("pod", pod.Name))
-		if !isPodReady(pod) {
-			log.Info("pod is not ready", log.String("daemon-set", ds.Name), log.String("pod", pod.Name))
-			return false
+		if !dsc.isAnyPodAvailable(&ds, dsPods) {
+			log.Info("pod is not available", log.String("daemon-set", ds.Name))
+			return &ds
 		}
 	}
 	log.Debug("all DaemonSets pods are available on node")
-	return true
+	return nil
 }
 
-func findDaemonSetPod(ds *apps.DaemonSet, pods []core.Pod) (*core.Pod, bool) {
+func (dsc *DaemonSetChecker) isAnyPodAvailable(ds *apps.DaemonSet, pods []core.Pod) bool {
+	for _, pod := range pods {
+		log.Debug("pod found", log.String("daemon-set", ds.Name), log.String("pod", pod.Name))
+		if !isPodReady(&pod) {
+			continue
+		}
+		if !dsc.conf.DaemonSetHC.Strict {
+			return true
+		}
+		if pod.DeletionTimestamp != nil {
+			log.Debug("pod is terminating", log.String("pod", pod.Name))
+			continue
+		}
+		if !isPodUpToDate(&pod, ds) {
+			log.Debug("pod is of outdated revision", log.String("pod", pod.Name))
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func findDaemonSetPods(ds *apps.DaemonSet, pods []core.Pod) []core.Pod {
+	var dsPods []core.Pod
 	for _, pod := range pods {
 		if isPodOwnedByDs(&pod, ds) {
-			return &pod, true
+			dsPods = append(dsPods, pod)
 		}
 	}
-	return nil, false
+	return dsPods
+}
+
+// isPodUpToDate reports whether the pod runs the current DaemonSet template.
+// DaemonSets with OnDelete strategy never replace outdated pods, so any of their pods is up-to-date.
+func isPodUpToDate(pod *core.Pod, ds *apps.DaemonSet) bool {
+	if ds.Spec.UpdateStrategy.Type == apps.OnDeleteDaemonSetStrategyType {
+		return true
+	}
+	generation, found := ds.Annotations[templateGenerationAnnotation]
+	if !found {
+		status := ds.Status
+		return status.ObservedGeneration >= ds.Generation && status.UpdatedNumberScheduled == status.DesiredNumberScheduled
+	}
+	return pod.Labels[templateGenerationLabel] == generation
 }
 
 func isPodOwnedByDs(pod *core.Pod, ds *apps.DaemonSet) bool {
@@ -0,0 +1,187 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func newTestDaemonSetWithGeneration(generation string) apps.DaemonSet {
+	return apps.DaemonSet{ObjectMeta: meta.ObjectMeta{
+		Namespace:   "default",
+		Name:        "agent",
+		UID:         "ds-uid",
+		Annotations: map[string]string{templateGenerationAnnotation: generation},
+	}}
+}
+
+func newTestDaemonSetPod(name, generation string, ready bool) core.Pod {
+	readyStatus := core.ConditionFalse
+	if ready {
+		readyStatus = core.ConditionTrue
+	}
+	return core.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:            name,
+			Labels:          map[string]string{templateGenerationLabel: generation},
+			OwnerReferences: []meta.OwnerReference{{UID: "ds-uid"}},
+		},
+		Status: core.PodStatus{
+			Phase:      core.PodRunning,
+			Conditions: []core.PodCondition{{Type: core.PodReady, Status: readyStatus}},
+		},
+	}
+}
+
+func newTestDaemonSetChecker(strict bool) *DaemonSetChecker {
+	return &DaemonSetChecker{conf: Config{DaemonSetHC: DaemonSetHealthCheckConfig{Strict: strict}}}
+}
+
+func TestPodsAvailableIfReady(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(true)
+	ds := newTestDaemonSetWithGeneration("2")
+	pods := []core.Pod{newTestDaemonSetPod("agent-new", "2", true)}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.Nil(t, unavailable)
+}
+
+func TestPodsNotAvailableIfMissing(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(false)
+	ds := newTestDaemonSetWithGeneration("2")
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, nil)
+
+	// THEN
+	require.NotNil(t, unavailable)
+	require.Equal(t, "agent", unavailable.Name)
+}
+
+func TestPodsNotAvailableIfNotReady(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(false)
+	ds := newTestDaemonSetWithGeneration("2")
+	pods := []core.Pod{newTestDaemonSetPod("agent-new", "2", false)}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.NotNil(t, unavailable)
+	require.Equal(t, "agent", unavailable.Name)
+}
+
+func TestPodsAvailableIfOutdatedAndNotStrict(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(false)
+	ds := newTestDaemonSetWithGeneration("2")
+	pods := []core.Pod{newTestDaemonSetPod("agent-old", "1", true)}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.Nil(t, unavailable)
+}
+
+func TestPodsNotAvailableIfOutdatedAndStrict(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(true)
+	ds := newTestDaemonSetWithGeneration("2")
+	pods := []core.Pod{
+		newTestDaemonSetPod("agent-old", "1", true),
+		newTestDaemonSetPod("agent-new", "2", false),
+	}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.NotNil(t, unavailable)
+	require.Equal(t, "agent", unavailable.Name)
+}
+
+func TestPodsAvailableIfOutdatedAndOnDeleteStrategy(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(true)
+	ds := newTestDaemonSetWithGeneration("2")
+	ds.Spec.UpdateStrategy.Type = apps.OnDeleteDaemonSetStrategyType
+	pods := []core.Pod{newTestDaemonSetPod("agent-old", "1", true)}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.Nil(t, unavailable)
+}
+
+func TestPodsNotAvailableIfTerminatingAndStrict(t *testing.T) {
+	// GIVEN
+	checker := newTestDaemonSetChecker(true)
+	ds := newTestDaemonSetWithGeneration("2")
+	pod := newTestDaemonSetPod("agent-new", "2", true)
+	pod.DeletionTimestamp = &meta.Time{}
+	pods := []core.Pod{pod}
+
+	// WHEN
+	unavailable := checker.findUnavailableDaemonSet([]apps.DaemonSet{ds}, pods)
+
+	// THEN
+	require.NotNil(t, unavailable)
+	require.Equal(t, "agent", unavailable.Name)
+}
+
+func TestRecordBlockingOncePerDaemonSet(t *testing.T) {
+	// GIVEN
+	k8s := fake.NewClientset()
+	recorder, err := NewRecorder(k8s, "psl-k8s-health")
+	require.NoError(t, err)
+	checker := newTestDaemonSetChecker(false)
+	checker.recorder = recorder
+	checker.node = newWatchedNode(&core.Node{ObjectMeta: meta.ObjectMeta{Name: "node-1", UID: "node-uid"}})
+	ds := newTestDaemonSetWithGeneration("1")
+
+	// WHEN
+	checker.recordBlocking(context.Background(), &ds)
+	checker.recordBlocking(context.Background(), &ds)
+
+	// THEN
+	require.NoError(t, recorder.Flush(context.Background()))
+	events, err := k8s.CoreV1().Events(meta.NamespaceDefault).List(context.Background(), meta.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	event := events.Items[0]
+	require.Equal(t, "Node", event.InvolvedObject.Kind)
+	require.Equal(t, "node-1", event.InvolvedObject.Name)
+	require.Equal(t, "StartupBlockedByDaemonSet", event.Reason)
+	require.Equal(t, "Pod startups on the node are blocked until DaemonSet 'default/agent' is ready", event.Message)
+	require.EqualValues(t, 1, event.Count)
+}
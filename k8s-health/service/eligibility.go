@@ -0,0 +1,84 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	log "log/slog"
+)
+
+// daemonTolerations are added to every DaemonSet pod by the DaemonSet controller.
+var daemonTolerations = []core.Toleration{
+	{Key: core.TaintNodeNotReady, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoExecute},
+	{Key: core.TaintNodeUnreachable, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoExecute},
+	{Key: core.TaintNodeDiskPressure, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule},
+	{Key: core.TaintNodeMemoryPressure, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule},
+	{Key: core.TaintNodePIDPressure, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule},
+	{Key: core.TaintNodeUnschedulable, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule},
+}
+
+// daemonHostNetworkTolerations are added to host network DaemonSet pods by the DaemonSet controller.
+var daemonHostNetworkTolerations = []core.Toleration{
+	{Key: core.TaintNodeNetworkUnavailable, Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule},
+}
+
+// isEligibleOnNode reports whether the DaemonSet controller would schedule a DaemonSet pod on the node,
+// otherwise explains why it would not. DaemonSets the controller schedules on no node are not evaluated.
+func isEligibleOnNode(ds *apps.DaemonSet, node *core.Node) (bool, string) {
+	if isScheduledNowhere(ds) {
+		return false, "not scheduled on any node"
+	}
+	spec := ds.Spec.Template.Spec
+	if spec.NodeName != "" && spec.NodeName != node.Name {
+		return false, fmt.Sprintf("bound to node '%s'", spec.NodeName)
+	}
+
+	pod := &core.Pod{Spec: spec}
+	fitsAffinity, err := nodeaffinity.GetRequiredNodeAffinity(pod).Match(node)
+	if err != nil {
+		// DaemonSet controller can't evaluate malformed affinity either, so it schedules no pod on the node
+		log.Warn("DaemonSet node affinity is malformed, treating it as not eligible",
+			log.String("daemon-set", ds.Name),
+			log.Any("error", err))
+		return false, "node affinity is malformed"
+	}
+	if !fitsAffinity {
+		return false, "node selector or affinity does not match node"
+	}
+
+	tolerations := append(append([]core.Toleration{}, spec.Tolerations...), daemonTolerations...)
+	if spec.HostNetwork {
+		tolerations = append(tolerations, daemonHostNetworkTolerations...)
+	}
+	taint, untolerated := helpers.FindMatchingUntoleratedTaint(node.Spec.Taints, tolerations, func(t *core.Taint) bool {
+		return t.Effect == core.TaintEffectNoSchedule || t.Effect == core.TaintEffectNoExecute
+	})
+	if untolerated {
+		return false, fmt.Sprintf("node taint '%s' is not tolerated", taint.ToString())
+	}
+	return true, ""
+}
+
+// isScheduledNowhere reports whether up-to-date DaemonSet status states no node should run its pods.
+// A node the DaemonSet controller does not account yet is not blocked until the status is updated.
+func isScheduledNowhere(ds *apps.DaemonSet) bool {
+	return ds.Status.ObservedGeneration >= ds.Generation && ds.Status.DesiredNumberScheduled == 0
+}
@@ -0,0 +1,211 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestIsEligibleOnNode(t *testing.T) {
+	node := &core.Node{
+		ObjectMeta: meta.ObjectMeta{Name: "node-1", Labels: map[string]string{"pool": "gpu", "zone": "a"}},
+	}
+	taintedNode := func(taints ...core.Taint) *core.Node {
+		tainted := node.DeepCopy()
+		tainted.Spec.Taints = taints
+		return tainted
+	}
+	affinity := func(expressions ...core.NodeSelectorRequirement) *core.Affinity {
+		return &core.Affinity{NodeAffinity: &core.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &core.NodeSelector{
+				NodeSelectorTerms: []core.NodeSelectorTerm{{MatchExpressions: expressions}},
+			},
+		}}
+	}
+	noScheduleTaint := core.Taint{Key: "dedicated", Value: "db", Effect: core.TaintEffectNoSchedule}
+	noExecuteTaint := core.Taint{Key: "dedicated", Value: "db", Effect: core.TaintEffectNoExecute}
+	preferNoScheduleTaint := core.Taint{Key: "dedicated", Value: "db", Effect: core.TaintEffectPreferNoSchedule}
+	dedicatedToleration := core.Toleration{Key: "dedicated", Operator: core.TolerationOpEqual, Value: "db"}
+
+	tests := []struct {
+		name     string
+		spec     core.PodSpec
+		status   apps.DaemonSetStatus
+		node     *core.Node
+		eligible bool
+	}{
+		{
+			name:     "no constraints",
+			node:     node,
+			eligible: true,
+		},
+		{
+			name:     "node selector matches",
+			spec:     core.PodSpec{NodeSelector: map[string]string{"pool": "gpu"}},
+			node:     node,
+			eligible: true,
+		},
+		{
+			name:     "node selector does not match",
+			spec:     core.PodSpec{NodeSelector: map[string]string{"pool": "cpu"}},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name: "required affinity In matches",
+			spec: core.PodSpec{Affinity: affinity(core.NodeSelectorRequirement{
+				Key: "zone", Operator: core.NodeSelectorOpIn, Values: []string{"a", "b"},
+			})},
+			node:     node,
+			eligible: true,
+		},
+		{
+			name: "required affinity NotIn does not match",
+			spec: core.PodSpec{Affinity: affinity(core.NodeSelectorRequirement{
+				Key: "zone", Operator: core.NodeSelectorOpNotIn, Values: []string{"a"},
+			})},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name: "required affinity DoesNotExist does not match",
+			spec: core.PodSpec{Affinity: affinity(core.NodeSelectorRequirement{
+				Key: "pool", Operator: core.NodeSelectorOpDoesNotExist,
+			})},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name:     "bound to another node",
+			spec:     core.PodSpec{NodeName: "node-2"},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name:     "NoSchedule taint not tolerated",
+			node:     taintedNode(noScheduleTaint),
+			eligible: false,
+		},
+		{
+			name:     "NoExecute taint not tolerated",
+			node:     taintedNode(noExecuteTaint),
+			eligible: false,
+		},
+		{
+			name:     "PreferNoSchedule taint ignored",
+			node:     taintedNode(preferNoScheduleTaint),
+			eligible: true,
+		},
+		{
+			name:     "taints tolerated",
+			spec:     core.PodSpec{Tolerations: []core.Toleration{dedicatedToleration}},
+			node:     taintedNode(noScheduleTaint, noExecuteTaint),
+			eligible: true,
+		},
+		{
+			name: "default daemon tolerations applied",
+			node: taintedNode(
+				core.Taint{Key: core.TaintNodeNotReady, Effect: core.TaintEffectNoExecute},
+				core.Taint{Key: core.TaintNodeUnschedulable, Effect: core.TaintEffectNoSchedule},
+			),
+			eligible: true,
+		},
+		{
+			name:     "network unavailable not tolerated without host network",
+			node:     taintedNode(core.Taint{Key: core.TaintNodeNetworkUnavailable, Effect: core.TaintEffectNoSchedule}),
+			eligible: false,
+		},
+		{
+			name:     "network unavailable tolerated with host network",
+			spec:     core.PodSpec{HostNetwork: true},
+			node:     taintedNode(core.Taint{Key: core.TaintNodeNetworkUnavailable, Effect: core.TaintEffectNoSchedule}),
+			eligible: true,
+		},
+		{
+			name: "malformed affinity and scheduled somewhere",
+			spec: core.PodSpec{Affinity: affinity(core.NodeSelectorRequirement{
+				Key: "zone", Operator: core.NodeSelectorOpGt, Values: []string{"a"},
+			})},
+			status:   apps.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name: "malformed affinity and scheduled nowhere",
+			spec: core.PodSpec{Affinity: affinity(core.NodeSelectorRequirement{
+				Key: "zone", Operator: core.NodeSelectorOpGt, Values: []string{"a"},
+			})},
+			status:   apps.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 0},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name:     "scheduled nowhere",
+			status:   apps.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 0},
+			node:     node,
+			eligible: false,
+		},
+		{
+			name:     "scheduled nowhere by outdated status",
+			status:   apps.DaemonSetStatus{ObservedGeneration: 0, DesiredNumberScheduled: 0},
+			node:     node,
+			eligible: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// GIVEN
+			ds := &apps.DaemonSet{
+				ObjectMeta: meta.ObjectMeta{Namespace: "default", Name: "agent", Generation: 1},
+				Spec:       apps.DaemonSetSpec{Template: core.PodTemplateSpec{Spec: test.spec}},
+				Status:     test.status,
+			}
+
+			// WHEN
+			eligible, reason := isEligibleOnNode(ds, test.node)
+
+			// THEN
+			require.Equal(t, test.eligible, eligible, reason)
+		})
+	}
+}
+
+func TestIsEligibleOnNodeIfAffinityOperatorInvalid(t *testing.T) {
+	// GIVEN
+	ds := &apps.DaemonSet{
+		ObjectMeta: meta.ObjectMeta{Namespace: "default", Name: "agent", Generation: 1},
+		Spec: apps.DaemonSetSpec{Template: core.PodTemplateSpec{Spec: core.PodSpec{Affinity: &core.Affinity{NodeAffinity: &core.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &core.NodeSelector{NodeSelectorTerms: []core.NodeSelectorTerm{{
+				MatchExpressions: []core.NodeSelectorRequirement{{Key: "zone", Operator: "Matches", Values: []string{"a"}}},
+			}}},
+		}}}}},
+		Status: apps.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3},
+	}
+	node := &core.Node{ObjectMeta: meta.ObjectMeta{Name: "node-1", Labels: map[string]string{"zone": "a"}}}
+
+	// WHEN
+	eligible, reason := isEligibleOnNode(ds, node)
+
+	// THEN
+	require.False(t, eligible)
+	require.Equal(t, "node affinity is malformed", reason)
+}
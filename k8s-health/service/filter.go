@@ -0,0 +1,105 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/common/util"
+	. "flakybit.net/psl/k8s-health/config"
+	apps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"path"
+	"strings"
+)
+
+// daemonSetFilter selects DaemonSets to check by labels, names and namespaces.
+// A DaemonSet is selected if it matches all include rules and none of exclude rules.
+type daemonSetFilter struct {
+	includeLabels   map[string]string
+	excludeLabels   map[string]string
+	includeSelector labels.Selector // Nil includes any labels
+	excludeSelector labels.Selector // Nil excludes none
+	includeNames    []string
+	excludeNames    []string
+}
+
+func newDaemonSetFilter(conf DaemonSetHealthCheckConfig) (*daemonSetFilter, error) {
+	includeSelector, err := parseSelector(conf.IncludeSelector)
+	if err != nil {
+		return nil, err
+	}
+	excludeSelector, err := parseSelector(conf.ExcludeSelector)
+	if err != nil {
+		return nil, err
+	}
+	filter := &daemonSetFilter{
+		conf.Include,
+		conf.Exclude,
+		includeSelector,
+		excludeSelector,
+		conf.IncludeNames,
+		conf.ExcludeNames,
+	}
+	return filter, nil
+}
+
+// parseSelector returns nil for a blank selector, unlike labels.Parse returning one matching everything,
+// so that callers tell an unset selector from a set one.
+func parseSelector(selector string) (labels.Selector, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+	return labels.Parse(selector)
+}
+
+// match reports whether the DaemonSet is selected, otherwise explains why it is not.
+func (f *daemonSetFilter) match(ds *apps.DaemonSet) (bool, string) {
+	dsLabels := labels.Set(ds.Labels)
+	if len(f.excludeLabels) > 0 && MapContainsAny(ds.Labels, f.excludeLabels) {
+		return false, "matches exclude labels"
+	}
+	if f.excludeSelector != nil && f.excludeSelector.Matches(dsLabels) {
+		return false, "matches exclude selector"
+	}
+	if len(f.excludeNames) > 0 && matchesAnyName(ds, f.excludeNames) {
+		return false, "matches exclude names"
+	}
+	if len(f.includeLabels) > 0 && !MapContainsAll(ds.Labels, f.includeLabels) {
+		return false, "not matches include labels"
+	}
+	if f.includeSelector != nil && !f.includeSelector.Matches(dsLabels) {
+		return false, "not matches include selector"
+	}
+	if len(f.includeNames) > 0 && !matchesAnyName(ds, f.includeNames) {
+		return false, "not matches include names"
+	}
+	return true, ""
+}
+
+// matchesAnyName matches "namespace/name" patterns against the DaemonSet namespace and name,
+// patterns without a namespace match the name in any namespace.
+func matchesAnyName(ds *apps.DaemonSet, patterns []string) bool {
+	for _, pattern := range patterns {
+		subject := ds.Name
+		if strings.Contains(pattern, "/") {
+			subject = ds.Namespace + "/" + ds.Name
+		}
+		if matched, _ := path.Match(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
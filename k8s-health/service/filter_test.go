@@ -0,0 +1,153 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newTestDaemonSet(namespace, name string, labels map[string]string) *apps.DaemonSet {
+	return &apps.DaemonSet{ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func TestFilterMatchesIfEmpty(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{})
+
+	// WHEN
+	matched, _ := filter.match(newTestDaemonSet("default", "agent", nil))
+
+	// THEN
+	require.True(t, matched)
+}
+
+func TestFilterMatchesIncludeSelectorSetExpression(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{IncludeSelector: "tier in (network,storage)"})
+
+	// WHEN
+	matched, _ := filter.match(newTestDaemonSet("default", "cni", map[string]string{"tier": "network"}))
+
+	// THEN
+	require.True(t, matched)
+}
+
+func TestFilterNotMatchesIncludeSelectorExistence(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{IncludeSelector: "critical"})
+
+	// WHEN
+	matched, reason := filter.match(newTestDaemonSet("default", "agent", map[string]string{"tier": "network"}))
+
+	// THEN
+	require.False(t, matched)
+	require.Equal(t, "not matches include selector", reason)
+}
+
+func TestFilterMatchesExcludeSelectorNonExistence(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{ExcludeSelector: "!critical"})
+
+	// WHEN
+	matched, reason := filter.match(newTestDaemonSet("default", "agent", nil))
+
+	// THEN
+	require.False(t, matched)
+	require.Equal(t, "matches exclude selector", reason)
+}
+
+func TestFilterAppliesIncludeAndExcludeSelectors(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{
+		IncludeSelector: "tier in (network,storage)",
+		ExcludeSelector: "tier notin (network)",
+	})
+
+	// WHEN
+	network, _ := filter.match(newTestDaemonSet("default", "cni", map[string]string{"tier": "network"}))
+	storage, _ := filter.match(newTestDaemonSet("default", "csi", map[string]string{"tier": "storage"}))
+
+	// THEN
+	require.True(t, network)
+	require.False(t, storage)
+}
+
+func TestFilterExcludesNamespacedNamePattern(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{ExcludeNames: []string{"kube-system/*-debug"}})
+
+	// WHEN
+	debug, reason := filter.match(newTestDaemonSet("kube-system", "proxy-debug", nil))
+	other, _ := filter.match(newTestDaemonSet("default", "proxy-debug", nil))
+
+	// THEN
+	require.False(t, debug)
+	require.Equal(t, "matches exclude names", reason)
+	require.True(t, other)
+}
+
+func TestFilterIncludesNamePatternInAnyNamespace(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{IncludeNames: []string{"cni-*"}})
+
+	// WHEN
+	cni, _ := filter.match(newTestDaemonSet("kube-system", "cni-calico", nil))
+	other, reason := filter.match(newTestDaemonSet("kube-system", "proxy", nil))
+
+	// THEN
+	require.True(t, cni)
+	require.False(t, other)
+	require.Equal(t, "not matches include names", reason)
+}
+
+func TestFilterIncludesNamespacePattern(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{IncludeNames: []string{"kube-system/*"}})
+
+	// WHEN
+	system, _ := filter.match(newTestDaemonSet("kube-system", "proxy", nil))
+	other, _ := filter.match(newTestDaemonSet("default", "proxy", nil))
+
+	// THEN
+	require.True(t, system)
+	require.False(t, other)
+}
+
+func TestFilterKeepsLegacyExcludeLabels(t *testing.T) {
+	// GIVEN
+	filter, _ := newDaemonSetFilter(DaemonSetHealthCheckConfig{Exclude: map[string]string{"a": "1", "b": "2"}})
+
+	// WHEN
+	matched, reason := filter.match(newTestDaemonSet("default", "agent", map[string]string{"b": "2"}))
+
+	// THEN
+	require.False(t, matched)
+	require.Equal(t, "matches exclude labels", reason)
+}
+
+func TestFilterRejectsMalformedSelector(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := newDaemonSetFilter(DaemonSetHealthCheckConfig{IncludeSelector: "tier in (network"})
+
+	// THEN
+	require.Error(t, err)
+}
@@ -18,42 +18,123 @@ package service
 
 import (
 	"context"
+	. "flakybit.net/psl/common"
 	. "flakybit.net/psl/k8s-health/client"
 	. "flakybit.net/psl/k8s-health/config"
 	log "log/slog"
+	"strings"
 )
 
+type registeredChecker struct {
+	checker HealthChecker
+	enabled bool
+	mode    string
+}
+
+// HealthCheckService aggregates registered checkers. Node is healthy if all enabled required checkers are healthy,
+// advisory checkers are reported only.
 type HealthCheckService struct {
-	conf        Config
-	client      *K8sClient
-	dsChecker   *DaemonSetChecker
-	loadChecker *NodeLoadChecker
+	conf     Config
+	client   *K8sClient
+	checkers []registeredChecker
 }
 
-func NewHealthCheckService(ctx context.Context, conf Config, k8sClient *K8sClient) *HealthCheckService {
-	nodeInfo := k8sClient.GetNodeInfo(ctx, conf.NodeName)
-	hcSvc := HealthCheckService{
-		conf,
-		k8sClient,
-		NewDaemonSetChecker(conf, k8sClient, nodeInfo),
-		NewNodeLoadChecker(conf, k8sClient, nodeInfo),
+func NewHealthCheckService(ctx context.Context, conf Config, k8sClient *K8sClient) (*HealthCheckService, error) {
+	nodeInfo, err := k8sClient.GetNodeInfo(ctx, conf.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	dsChecker, err := NewDaemonSetChecker(conf, k8sClient, nodeInfo)
+	if err != nil {
+		return nil, err
 	}
-	log.Info("configured health check service",
-		log.Bool("daemon-set-check", conf.DaemonSetHC.Enabled),
-		log.Bool("node-load-check", conf.NodeLoadHC.Enabled))
-	return &hcSvc
+	wlChecker, err := NewWorkloadChecker(conf, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	hcSvc := &HealthCheckService{conf: conf, client: k8sClient}
+	hcSvc.Register(dsChecker, conf.DaemonSetHC.Enabled, conf.DaemonSetHC.Mode)
+	hcSvc.Register(NewNodeLoadChecker(conf, k8sClient, nodeInfo), conf.NodeLoadHC.Enabled, conf.NodeLoadHC.Mode)
+	hcSvc.Register(wlChecker, conf.WorkloadHC.Enabled, conf.WorkloadHC.Mode)
+	hcSvc.Register(NewNodeChecker(conf, k8sClient), conf.NodeHC.Enabled, conf.NodeHC.Mode)
+	log.Info("configured health check service")
+	return hcSvc, nil
+}
+
+// Register adds the checker to the service, must be called before Run.
+func (hcs *HealthCheckService) Register(checker HealthChecker, enabled bool, mode string) {
+	hcs.checkers = append(hcs.checkers, registeredChecker{checker, enabled, mode})
+	log.Info("registered health checker",
+		log.String("name", checker.Name()),
+		log.Bool("enabled", enabled),
+		log.String("mode", mode))
+}
+
+func (hcs *HealthCheckService) Name() string {
+	return "k8s-health"
 }
+
 func (hcs *HealthCheckService) Run(ctx context.Context) {
-	if hcs.conf.DaemonSetHC.Enabled {
-		go hcs.dsChecker.Run(ctx)
-	}
-	if hcs.conf.NodeLoadHC.Enabled {
-		go hcs.loadChecker.Run(ctx)
+	for _, rc := range hcs.checkers {
+		if rc.enabled {
+			go rc.checker.Run(ctx)
+		}
 	}
 }
 
 func (hcs *HealthCheckService) IsHealthy() bool {
-	healthy := hcs.dsChecker.IsHealthy() && hcs.loadChecker.IsHealthy()
+	healthy := true
+	for _, status := range hcs.HealthStatuses() {
+		if status.Required && !status.Healthy {
+			healthy = false
+		}
+	}
 	log.Debug("overall health status", log.Bool("status", healthy))
 	return healthy
 }
+
+// Reason lists reasons of unhealthy required checkers, which make the node unhealthy.
+func (hcs *HealthCheckService) Reason() string {
+	reason := hcs.failures(true)
+	if reason != "" {
+		return reason
+	}
+	if hcs.failures(false) != "" {
+		return "all required checks passed"
+	}
+	return "all checks passed"
+}
+
+// AdvisoryReason lists reasons of unhealthy advisory checkers, which are reported only.
+func (hcs *HealthCheckService) AdvisoryReason() string {
+	return hcs.failures(false)
+}
+
+// failures lists reasons of unhealthy required or advisory checkers, empty if all are healthy.
+func (hcs *HealthCheckService) failures(required bool) string {
+	var reasons []string
+	for _, status := range hcs.HealthStatuses() {
+		if !status.Healthy && status.Required == required {
+			reasons = append(reasons, status.Name+": "+status.Reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// HealthStatuses returns results of enabled checkers.
+func (hcs *HealthCheckService) HealthStatuses() []HealthStatus {
+	var statuses []HealthStatus
+	for _, rc := range hcs.checkers {
+		if !rc.enabled {
+			continue
+		}
+		statuses = append(statuses, HealthStatus{
+			Name:     rc.checker.Name(),
+			Healthy:  rc.checker.IsHealthy(),
+			Required: rc.mode == ModeRequired,
+			Reason:   rc.checker.Reason(),
+		})
+	}
+	return statuses
+}
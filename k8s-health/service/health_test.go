@@ -0,0 +1,122 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type stubChecker struct {
+	name    string
+	healthy bool
+}
+
+func (c *stubChecker) Name() string            { return c.name }
+func (c *stubChecker) Run(ctx context.Context) {}
+func (c *stubChecker) IsHealthy() bool         { return c.healthy }
+func (c *stubChecker) Reason() string          { return c.name + " reason" }
+
+func TestHealthyIfAllRequiredHealthy(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", true}, true, ModeRequired)
+	hcs.Register(&stubChecker{"b", true}, true, ModeRequired)
+
+	// WHEN
+	healthy := hcs.IsHealthy()
+
+	// THEN
+	require.True(t, healthy)
+}
+
+func TestUnhealthyIfRequiredUnhealthy(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", true}, true, ModeRequired)
+	hcs.Register(&stubChecker{"b", false}, true, ModeRequired)
+
+	// WHEN
+	healthy := hcs.IsHealthy()
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "b: b reason", hcs.Reason())
+}
+
+func TestHealthyIfAdvisoryUnhealthy(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", true}, true, ModeRequired)
+	hcs.Register(&stubChecker{"b", false}, true, ModeAdvisory)
+
+	// WHEN
+	healthy := hcs.IsHealthy()
+
+	// THEN
+	require.True(t, healthy)
+	require.Equal(t, "all required checks passed", hcs.Reason())
+	require.Equal(t, "b: b reason", hcs.AdvisoryReason())
+}
+
+func TestReasonIfRequiredAndAdvisoryUnhealthy(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", false}, true, ModeRequired)
+	hcs.Register(&stubChecker{"b", false}, true, ModeAdvisory)
+
+	// WHEN
+	reason := hcs.Reason()
+	advisoryReason := hcs.AdvisoryReason()
+
+	// THEN
+	require.Equal(t, "a: a reason", reason)
+	require.Equal(t, "b: b reason", advisoryReason)
+}
+
+func TestHealthyIfDisabledUnhealthy(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", false}, false, ModeRequired)
+
+	// WHEN
+	healthy := hcs.IsHealthy()
+
+	// THEN
+	require.True(t, healthy)
+	require.Empty(t, hcs.HealthStatuses())
+}
+
+func TestHealthStatusesPerChecker(t *testing.T) {
+	// GIVEN
+	hcs := &HealthCheckService{}
+	hcs.Register(&stubChecker{"a", true}, true, ModeRequired)
+	hcs.Register(&stubChecker{"b", false}, true, ModeAdvisory)
+
+	// WHEN
+	statuses := hcs.HealthStatuses()
+
+	// THEN
+	require.Len(t, statuses, 2)
+	require.Equal(t, "a", statuses[0].Name)
+	require.True(t, statuses[0].Required)
+	require.Equal(t, "b", statuses[1].Name)
+	require.False(t, statuses[1].Healthy)
+	require.False(t, statuses[1].Required)
+}
@@ -0,0 +1,94 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/k8s-health/client"
+	. "flakybit.net/psl/k8s-health/config"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	log "log/slog"
+	"slices"
+)
+
+// NodeChecker gates on node conditions and startup taints, which CNI or storage drivers hold while initializing.
+type NodeChecker struct {
+	conf   Config
+	client *K8sClient
+	checkerState
+}
+
+func NewNodeChecker(conf Config, client *K8sClient) *NodeChecker {
+	checker := &NodeChecker{conf, client, checkerState{reason: notCheckedReason}}
+	log.Info("configured node checker", log.Any("taints", conf.NodeHC.Taints))
+	return checker
+}
+
+func (nc *NodeChecker) Name() string {
+	return "node"
+}
+
+func (nc *NodeChecker) Run(ctx context.Context) {
+	err := nc.client.WatchNode(ctx, func(node *core.Node) {
+		checkStatus, reason := nc.check(node)
+		if checkStatus != nc.IsHealthy() {
+			log.Info("node health check status changed",
+				log.Bool("old", nc.IsHealthy()),
+				log.Bool("new", checkStatus),
+				log.String("reason", reason))
+		}
+		log.Debug("performed node health check", log.Bool("healthy", checkStatus))
+		nc.set(checkStatus, reason)
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to watch node", log.Any("error", err))
+		nc.set(false, err.Error())
+	}
+}
+
+func (nc *NodeChecker) check(node *core.Node) (bool, string) {
+	if node == nil {
+		return false, "node is deleted"
+	}
+	return checkNodeConditions(node, nc.conf.NodeHC.Taints)
+}
+
+// checkNodeConditions requires node to be Ready, to have network available and none of the listed taints.
+// Missing NetworkUnavailable condition is treated as available, since not every network plugin reports it.
+func checkNodeConditions(node *core.Node, taints []string) (bool, string) {
+	ready := false
+	for _, cond := range node.Status.Conditions {
+		switch cond.Type {
+		case core.NodeReady:
+			ready = cond.Status == core.ConditionTrue
+		case core.NodeNetworkUnavailable:
+			if cond.Status != core.ConditionFalse {
+				return false, fmt.Sprintf("node network is unavailable: %s", cond.Reason)
+			}
+		}
+	}
+	if !ready {
+		return false, "node is not ready"
+	}
+	for _, taint := range node.Spec.Taints {
+		if slices.Contains(taints, taint.Key) {
+			return false, fmt.Sprintf("node has taint '%s'", taint.ToString())
+		}
+	}
+	return true, "node is ready"
+}
@@ -0,0 +1,95 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"testing"
+)
+
+var startupTaints = []string{core.TaintNodeNotReady, "example.com/storage-not-ready"}
+
+func newTestNode(ready, networkUnavailable core.ConditionStatus, taints ...core.Taint) *core.Node {
+	node := &core.Node{}
+	node.Spec.Taints = taints
+	node.Status.Conditions = []core.NodeCondition{{Type: core.NodeReady, Status: ready}}
+	if networkUnavailable != "" {
+		node.Status.Conditions = append(node.Status.Conditions,
+			core.NodeCondition{Type: core.NodeNetworkUnavailable, Status: networkUnavailable})
+	}
+	return node
+}
+
+func TestNodeHealthyIfReadyWithoutNetworkCondition(t *testing.T) {
+	// GIVEN
+	node := newTestNode(core.ConditionTrue, "")
+
+	// WHEN
+	healthy, _ := checkNodeConditions(node, startupTaints)
+
+	// THEN
+	require.True(t, healthy)
+}
+
+func TestNodeUnhealthyIfNotReady(t *testing.T) {
+	// GIVEN
+	node := newTestNode(core.ConditionFalse, core.ConditionFalse)
+
+	// WHEN
+	healthy, reason := checkNodeConditions(node, startupTaints)
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "node is not ready", reason)
+}
+
+func TestNodeUnhealthyIfNetworkUnavailable(t *testing.T) {
+	// GIVEN
+	node := newTestNode(core.ConditionTrue, core.ConditionTrue)
+
+	// WHEN
+	healthy, _ := checkNodeConditions(node, startupTaints)
+
+	// THEN
+	require.False(t, healthy)
+}
+
+func TestNodeUnhealthyIfStartupTaint(t *testing.T) {
+	// GIVEN
+	node := newTestNode(core.ConditionTrue, core.ConditionFalse,
+		core.Taint{Key: "example.com/storage-not-ready", Effect: core.TaintEffectNoSchedule})
+
+	// WHEN
+	healthy, reason := checkNodeConditions(node, startupTaints)
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "node has taint 'example.com/storage-not-ready:NoSchedule'", reason)
+}
+
+func TestNodeHealthyIfOtherTaint(t *testing.T) {
+	// GIVEN
+	node := newTestNode(core.ConditionTrue, core.ConditionFalse,
+		core.Taint{Key: "dedicated", Value: "db", Effect: core.TaintEffectNoSchedule})
+
+	// WHEN
+	healthy, _ := checkNodeConditions(node, startupTaints)
+
+	// THEN
+	require.True(t, healthy)
+}
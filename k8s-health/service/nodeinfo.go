@@ -0,0 +1,58 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/k8s-health/client"
+	core "k8s.io/api/core/v1"
+	log "log/slog"
+	"sync"
+)
+
+// watchedNode holds the latest known state of the node, safe for concurrent use.
+// It starts with the node fetched at startup and is refreshed by the Node informer.
+type watchedNode struct {
+	mutex sync.RWMutex
+	node  *core.Node
+}
+
+func newWatchedNode(node *core.Node) *watchedNode {
+	return &watchedNode{node: node}
+}
+
+func (wn *watchedNode) get() *core.Node {
+	wn.mutex.RLock()
+	defer wn.mutex.RUnlock()
+	return wn.node
+}
+
+// watch keeps the node up to date and calls onChange with the previous and the refreshed node.
+// Deletion is ignored, so checkers keep working with the last known node.
+func (wn *watchedNode) watch(ctx context.Context, client *K8sClient, onChange func(old, node *core.Node)) error {
+	return client.WatchNode(ctx, func(node *core.Node) {
+		if node == nil {
+			return
+		}
+		wn.mutex.Lock()
+		old := wn.node
+		wn.node = node
+		wn.mutex.Unlock()
+		log.Debug("refreshed node info", log.String("resource-version", node.ResourceVersion))
+		onChange(old, node)
+	})
+}
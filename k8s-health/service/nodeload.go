@@ -20,6 +20,7 @@ import (
 	"context"
 	. "flakybit.net/psl/k8s-health/client"
 	. "flakybit.net/psl/k8s-health/config"
+	"fmt"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	log "log/slog"
@@ -31,46 +32,62 @@ import (
 // https://stackoverflow.com/questions/52029656/how-to-retrieve-kubernetes-metrics-via-client-go-and-golang
 
 type NodeLoadChecker struct {
-	conf            Config
-	client          *K8sClient
-	nodeCpuCapacity *resource.Quantity
-	healthy         bool
+	conf       Config
+	client     *K8sClient
+	node       *watchedNode
+	smoother   loadSmoother
+	hysteresis *loadHysteresis
+	stale      *staleGuard
+	checkerState
 }
 
 func NewNodeLoadChecker(conf Config, client *K8sClient, node *core.Node) *NodeLoadChecker {
-	cpuCap := node.Status.Capacity.Cpu()
 	checker := &NodeLoadChecker{
 		conf,
 		client,
-		cpuCap,
-		false,
+		newWatchedNode(node),
+		newLoadSmoother(conf.NodeLoadHC),
+		newLoadHysteresis(conf.NodeLoadHC),
+		newStaleGuard(conf.StaleTtl),
+		checkerState{reason: notCheckedReason},
 	}
 	log.Info("configured node load checker",
-		log.String("cpu-capacity", cpuCap.String()),
-		log.Int("threshold", conf.NodeLoadHC.CpuThreshold))
+		log.String("cpu-base", conf.NodeLoadHC.CpuBase),
+		log.String("cpu", nodeCpu(node, conf.NodeLoadHC.CpuBase).String()),
+		log.Int("threshold", conf.NodeLoadHC.CpuThreshold),
+		log.Int("recovery-threshold", conf.NodeLoadHC.RecoveryThreshold()),
+		log.String("smoothing", conf.NodeLoadHC.Smoothing))
 	return checker
 }
 
-func (nlc *NodeLoadChecker) IsHealthy() bool {
-	if !nlc.conf.NodeLoadHC.Enabled {
-		return true
-	}
-	return nlc.healthy
+func (nlc *NodeLoadChecker) Name() string {
+	return "node-load"
 }
 
 func (nlc *NodeLoadChecker) Run(ctx context.Context) {
+	err := nlc.node.watch(ctx, nlc.client, func(old, node *core.Node) {
+		oldCpu, newCpu := nodeCpu(old, nlc.conf.NodeLoadHC.CpuBase), nodeCpu(node, nlc.conf.NodeLoadHC.CpuBase)
+		if !oldCpu.Equal(*newCpu) {
+			log.Info("node CPU changed", log.String("old", oldCpu.String()), log.String("new", newCpu.String()))
+		}
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to watch node, using node info fetched at startup", log.Any("error", err))
+	}
+
 	ticker := time.NewTicker(nlc.conf.NodeLoadHC.Period)
 	defer ticker.Stop()
 
 	for {
-		checkStatus := nlc.check(ctx)
-		if checkStatus != nlc.healthy {
+		checkStatus, reason := nlc.evaluate(ctx)
+		if checkStatus != nlc.IsHealthy() {
 			log.Info("node load check status changed",
-				log.Bool("old", nlc.healthy),
-				log.Bool("new", checkStatus))
+				log.Bool("old", nlc.IsHealthy()),
+				log.Bool("new", checkStatus),
+				log.String("reason", reason))
 		}
 		log.Debug("performed node load health check", log.Bool("healthy", checkStatus))
-		nlc.healthy = checkStatus
+		nlc.set(checkStatus, reason)
 
 		select {
 		case <-ticker.C:
@@ -81,15 +98,55 @@ func (nlc *NodeLoadChecker) Run(ctx context.Context) {
 	}
 }
 
-func (nlc *NodeLoadChecker) check(ctx context.Context) bool {
-	metrics := nlc.client.GetNodeMetrics(ctx, nlc.conf.NodeName)
+// evaluate performs the check and falls back to the last known result if it is not stale yet.
+func (nlc *NodeLoadChecker) evaluate(ctx context.Context) (bool, string) {
+	now := time.Now()
+	load, err := nlc.check(ctx)
+	if err == nil {
+		nlc.stale.succeeded(now)
+		healthy := nlc.hysteresis.update(load, now)
+		if healthy {
+			return true, fmt.Sprintf("node CPU usage is %.0f%%", load)
+		}
+		return false, fmt.Sprintf("node CPU usage is %.0f%%, overloaded", load)
+	}
+	if nlc.stale.trusted(now) {
+		log.WarnContext(ctx, "failed to check node load, trusting last known result",
+			log.Bool("healthy", nlc.IsHealthy()),
+			log.Any("error", err))
+		return nlc.IsHealthy(), nlc.Reason()
+	}
+	log.ErrorContext(ctx, "failed to check node load", log.Any("error", err))
+	return false, err.Error()
+}
+
+// check samples node CPU utilisation and returns its smoothed value in percent.
+func (nlc *NodeLoadChecker) check(ctx context.Context) (float64, error) {
+	metrics, err := nlc.client.GetNodeMetrics(ctx, nlc.conf.NodeName)
+	if err != nil {
+		return 0, err
+	}
+	cpuMilli := nodeCpu(nlc.node.get(), nlc.conf.NodeLoadHC.CpuBase).MilliValue()
+	if cpuMilli <= 0 {
+		return 0, fmt.Errorf("node '%s' reports no %s CPU", nlc.conf.NodeName, nlc.conf.NodeLoadHC.CpuBase)
+	}
 	cpuUsageMilli := metrics.Usage.Cpu().MilliValue()
-	cpuUsageShare := float64(cpuUsageMilli) / float64(nlc.nodeCpuCapacity.MilliValue())
-	cpuUsagePct := int(math.Round(cpuUsageShare * 100))
+	cpuUsageShare := float64(cpuUsageMilli) / float64(cpuMilli)
+	cpuUsagePct := cpuUsageShare * 100
+	cpuUsageSmoothed := math.Round(nlc.smoother.add(cpuUsagePct))
 	log.Debug("node CPU usage",
 		log.Int64("cpu-milli", cpuUsageMilli),
-		log.Int("cpu-pct", cpuUsagePct),
+		log.Int("cpu-pct", int(math.Round(cpuUsagePct))),
+		log.Int("cpu-pct-smoothed", int(cpuUsageSmoothed)),
 		log.Int("threshold", nlc.conf.NodeLoadHC.CpuThreshold))
 
-	return cpuUsagePct < nlc.conf.NodeLoadHC.CpuThreshold
+	return cpuUsageSmoothed, nil
+}
+
+// nodeCpu returns node CPU amount utilisation is relative to, allocatable excludes system and kube reserved CPU.
+func nodeCpu(node *core.Node, cpuBase string) *resource.Quantity {
+	if cpuBase == CpuBaseCapacity {
+		return node.Status.Capacity.Cpu()
+	}
+	return node.Status.Allocatable.Cpu()
 }
@@ -0,0 +1,54 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+)
+
+func newTestNodeWithCpu(capacity, allocatable string) *core.Node {
+	node := &core.Node{}
+	node.Status.Capacity = core.ResourceList{core.ResourceCPU: resource.MustParse(capacity)}
+	node.Status.Allocatable = core.ResourceList{core.ResourceCPU: resource.MustParse(allocatable)}
+	return node
+}
+
+func TestNodeCpuIsAllocatable(t *testing.T) {
+	// GIVEN
+	node := newTestNodeWithCpu("4", "3500m")
+
+	// WHEN
+	cpu := nodeCpu(node, CpuBaseAllocatable)
+
+	// THEN
+	require.Equal(t, int64(3500), cpu.MilliValue())
+}
+
+func TestNodeCpuIsCapacity(t *testing.T) {
+	// GIVEN
+	node := newTestNodeWithCpu("4", "3500m")
+
+	// WHEN
+	cpu := nodeCpu(node, CpuBaseCapacity)
+
+	// THEN
+	require.Equal(t, int64(4000), cpu.MilliValue())
+}
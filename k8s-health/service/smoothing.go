@@ -0,0 +1,121 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"time"
+)
+
+// loadSmoother turns raw CPU utilisation samples into the value health decisions are made on.
+type loadSmoother interface {
+	add(sample float64) float64
+}
+
+func newLoadSmoother(conf NodeLoadHealthCheckConfig) loadSmoother {
+	switch conf.Smoothing {
+	case SmoothingEwma:
+		return &ewmaSmoother{alpha: conf.EwmaAlpha}
+	case SmoothingWindow:
+		return &windowSmoother{size: conf.WindowSize}
+	default:
+		return &noSmoother{}
+	}
+}
+
+type noSmoother struct{}
+
+func (s *noSmoother) add(sample float64) float64 {
+	return sample
+}
+
+type ewmaSmoother struct {
+	alpha  float64
+	value  float64
+	primed bool
+}
+
+func (s *ewmaSmoother) add(sample float64) float64 {
+	if !s.primed {
+		s.value = sample
+		s.primed = true
+	} else {
+		s.value = s.alpha*sample + (1-s.alpha)*s.value
+	}
+	return s.value
+}
+
+type windowSmoother struct {
+	size    int
+	samples []float64
+	sum     float64
+}
+
+func (s *windowSmoother) add(sample float64) float64 {
+	s.samples = append(s.samples, sample)
+	s.sum += sample
+	if len(s.samples) > s.size {
+		s.sum -= s.samples[0]
+		s.samples = s.samples[1:]
+	}
+	return s.sum / float64(len(s.samples))
+}
+
+// loadHysteresis flips health state only when the load crosses the threshold of the opposite state
+// and stays there for the configured dwell time.
+type loadHysteresis struct {
+	unhealthyThreshold float64
+	healthyThreshold   float64
+	unhealthyAfter     time.Duration
+	healthyAfter       time.Duration
+	healthy            bool
+	pendingSince       time.Time
+}
+
+func newLoadHysteresis(conf NodeLoadHealthCheckConfig) *loadHysteresis {
+	return &loadHysteresis{
+		unhealthyThreshold: float64(conf.CpuThreshold),
+		healthyThreshold:   float64(conf.RecoveryThreshold()),
+		unhealthyAfter:     conf.UnhealthyAfter,
+		healthyAfter:       conf.HealthyAfter,
+	}
+}
+
+func (h *loadHysteresis) update(load float64, now time.Time) bool {
+	var flip bool
+	var dwell time.Duration
+	if h.healthy {
+		flip = load >= h.unhealthyThreshold
+		dwell = h.unhealthyAfter
+	} else {
+		flip = load < h.healthyThreshold
+		dwell = h.healthyAfter
+	}
+
+	if !flip {
+		h.pendingSince = time.Time{}
+		return h.healthy
+	}
+	if h.pendingSince.IsZero() {
+		h.pendingSince = now
+	}
+	if now.Sub(h.pendingSince) >= dwell {
+		h.healthy = !h.healthy
+		h.pendingSince = time.Time{}
+	}
+	return h.healthy
+}
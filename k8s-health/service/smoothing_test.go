@@ -0,0 +1,160 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNoSmootherReturnsSample(t *testing.T) {
+	// GIVEN
+	smoother := newLoadSmoother(NodeLoadHealthCheckConfig{Smoothing: SmoothingNone})
+	smoother.add(90)
+
+	// WHEN
+	value := smoother.add(10)
+
+	// THEN
+	require.Equal(t, 10.0, value)
+}
+
+func TestEwmaSmootherStartsWithFirstSample(t *testing.T) {
+	// GIVEN
+	smoother := newLoadSmoother(NodeLoadHealthCheckConfig{Smoothing: SmoothingEwma, EwmaAlpha: 0.5})
+
+	// WHEN
+	value := smoother.add(40)
+
+	// THEN
+	require.Equal(t, 40.0, value)
+}
+
+func TestEwmaSmootherWeightsLatestSample(t *testing.T) {
+	// GIVEN
+	smoother := newLoadSmoother(NodeLoadHealthCheckConfig{Smoothing: SmoothingEwma, EwmaAlpha: 0.5})
+	smoother.add(40)
+
+	// WHEN
+	value := smoother.add(80)
+
+	// THEN
+	require.Equal(t, 60.0, value)
+}
+
+func TestWindowSmootherAveragesPartialWindow(t *testing.T) {
+	// GIVEN
+	smoother := newLoadSmoother(NodeLoadHealthCheckConfig{Smoothing: SmoothingWindow, WindowSize: 3})
+	smoother.add(10)
+
+	// WHEN
+	value := smoother.add(20)
+
+	// THEN
+	require.Equal(t, 15.0, value)
+}
+
+func TestWindowSmootherDropsOldestSample(t *testing.T) {
+	// GIVEN
+	smoother := newLoadSmoother(NodeLoadHealthCheckConfig{Smoothing: SmoothingWindow, WindowSize: 2})
+	smoother.add(100)
+	smoother.add(10)
+
+	// WHEN
+	value := smoother.add(30)
+
+	// THEN
+	require.Equal(t, 20.0, value)
+}
+
+func TestHysteresisBecomesHealthyBelowRecoveryThreshold(t *testing.T) {
+	// GIVEN
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, CpuRecoveryThreshold: 60})
+
+	// WHEN
+	healthy := hysteresis.update(59, time.Now())
+
+	// THEN
+	require.True(t, healthy)
+}
+
+func TestHysteresisStaysUnhealthyBetweenThresholds(t *testing.T) {
+	// GIVEN
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, CpuRecoveryThreshold: 60})
+
+	// WHEN
+	healthy := hysteresis.update(70, time.Now())
+
+	// THEN
+	require.False(t, healthy)
+}
+
+func TestHysteresisStaysHealthyBetweenThresholds(t *testing.T) {
+	// GIVEN
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, CpuRecoveryThreshold: 60})
+	hysteresis.update(50, time.Now())
+
+	// WHEN
+	healthy := hysteresis.update(70, time.Now())
+
+	// THEN
+	require.True(t, healthy)
+}
+
+func TestHysteresisBecomesUnhealthyAboveThreshold(t *testing.T) {
+	// GIVEN
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, CpuRecoveryThreshold: 60})
+	hysteresis.update(50, time.Now())
+
+	// WHEN
+	healthy := hysteresis.update(80, time.Now())
+
+	// THEN
+	require.False(t, healthy)
+}
+
+func TestHysteresisWaitsForUnhealthyDwell(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, UnhealthyAfter: 30 * time.Second})
+	hysteresis.update(50, now)
+	hysteresis.update(90, now)
+
+	// WHEN
+	before := hysteresis.update(90, now.Add(20*time.Second))
+	after := hysteresis.update(90, now.Add(30*time.Second))
+
+	// THEN
+	require.True(t, before)
+	require.False(t, after)
+}
+
+func TestHysteresisResetsDwellOnDip(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	hysteresis := newLoadHysteresis(NodeLoadHealthCheckConfig{CpuThreshold: 80, HealthyAfter: 30 * time.Second})
+	hysteresis.update(50, now)
+	hysteresis.update(90, now.Add(20*time.Second))
+
+	// WHEN
+	healthy := hysteresis.update(50, now.Add(40*time.Second))
+
+	// THEN
+	require.False(t, healthy)
+}
@@ -0,0 +1,40 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"time"
+)
+
+// staleGuard tracks the last successful check and decides whether its result
+// may still be trusted when a subsequent check fails to reach K8s API.
+type staleGuard struct {
+	ttl         time.Duration
+	lastSuccess time.Time
+}
+
+func newStaleGuard(ttl time.Duration) *staleGuard {
+	return &staleGuard{ttl: ttl}
+}
+
+func (g *staleGuard) succeeded(now time.Time) {
+	g.lastSuccess = now
+}
+
+func (g *staleGuard) trusted(now time.Time) bool {
+	return !g.lastSuccess.IsZero() && now.Sub(g.lastSuccess) < g.ttl
+}
@@ -0,0 +1,73 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStaleGuardNotTrustedIfNeverSucceeded(t *testing.T) {
+	// GIVEN
+	guard := newStaleGuard(time.Minute)
+
+	// WHEN
+	trusted := guard.trusted(time.Now())
+
+	// THEN
+	require.False(t, trusted)
+}
+
+func TestStaleGuardTrustedWithinTtl(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	guard := newStaleGuard(time.Minute)
+	guard.succeeded(now)
+
+	// WHEN
+	trusted := guard.trusted(now.Add(30 * time.Second))
+
+	// THEN
+	require.True(t, trusted)
+}
+
+func TestStaleGuardNotTrustedAfterTtl(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	guard := newStaleGuard(time.Minute)
+	guard.succeeded(now)
+
+	// WHEN
+	trusted := guard.trusted(now.Add(time.Minute))
+
+	// THEN
+	require.False(t, trusted)
+}
+
+func TestStaleGuardNotTrustedIfTtlZero(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	guard := newStaleGuard(0)
+	guard.succeeded(now)
+
+	// WHEN
+	trusted := guard.trusted(now)
+
+	// THEN
+	require.False(t, trusted)
+}
@@ -0,0 +1,50 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"sync"
+)
+
+const notCheckedReason = "not checked yet"
+
+// checkerState holds the latest result of a checker, safe for concurrent use.
+type checkerState struct {
+	mutex   sync.RWMutex
+	healthy bool
+	reason  string
+}
+
+func (s *checkerState) IsHealthy() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.healthy
+}
+
+// Reason explains the current health status.
+func (s *checkerState) Reason() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.reason
+}
+
+func (s *checkerState) set(healthy bool, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.healthy = healthy
+	s.reason = reason
+}
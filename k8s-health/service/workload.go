@@ -0,0 +1,182 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	. "flakybit.net/psl/k8s-health/client"
+	. "flakybit.net/psl/k8s-health/config"
+	"fmt"
+	discovery "k8s.io/api/discovery/v1"
+	log "log/slog"
+	"time"
+)
+
+type WorkloadChecker struct {
+	conf    Config
+	client  *K8sClient
+	targets []WorkloadRef
+	stale   *staleGuard
+	checkerState
+}
+
+func NewWorkloadChecker(conf Config, client *K8sClient) (*WorkloadChecker, error) {
+	var targets []WorkloadRef
+	for _, target := range conf.WorkloadHC.Targets {
+		ref, err := ParseWorkloadRef(target)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, ref)
+	}
+	checker := &WorkloadChecker{conf, client, targets, newStaleGuard(conf.StaleTtl), checkerState{reason: notCheckedReason}}
+	log.Info("configured workload checker", log.Any("targets", conf.WorkloadHC.Targets))
+	return checker, nil
+}
+
+func (wc *WorkloadChecker) Name() string {
+	return "workload"
+}
+
+func (wc *WorkloadChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(wc.conf.WorkloadHC.PeriodOnFail)
+	defer ticker.Stop()
+
+	for {
+		checkStatus, reason := wc.evaluate(ctx)
+		if checkStatus != wc.IsHealthy() {
+			log.Info("workload health check status changed",
+				log.Bool("old", wc.IsHealthy()),
+				log.Bool("new", checkStatus),
+				log.String("reason", reason))
+			if checkStatus {
+				ticker.Reset(wc.conf.WorkloadHC.PeriodOnPass)
+			} else {
+				ticker.Reset(wc.conf.WorkloadHC.PeriodOnFail)
+			}
+		}
+		log.Debug("performed workload health check", log.Bool("healthy", checkStatus))
+		wc.set(checkStatus, reason)
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate performs the check and falls back to the last known result if it is not stale yet.
+func (wc *WorkloadChecker) evaluate(ctx context.Context) (bool, string) {
+	now := time.Now()
+	healthy, reason, err := wc.check(ctx)
+	if err == nil {
+		wc.stale.succeeded(now)
+		return healthy, reason
+	}
+	if wc.stale.trusted(now) {
+		log.WarnContext(ctx, "failed to check workloads, trusting last known result",
+			log.Bool("healthy", wc.IsHealthy()),
+			log.Any("error", err))
+		return wc.IsHealthy(), wc.Reason()
+	}
+	log.ErrorContext(ctx, "failed to check workloads", log.Any("error", err))
+	return false, err.Error()
+}
+
+func (wc *WorkloadChecker) check(ctx context.Context) (bool, string, error) {
+	for _, target := range wc.targets {
+		ready, desired, err := wc.countReady(ctx, target)
+		if err != nil {
+			return false, "", err
+		}
+		if ready < desired {
+			log.Info("workload is not ready",
+				log.String("workload", target.String()),
+				log.Int("desired", desired),
+				log.Int("ready", ready))
+			return false, fmt.Sprintf("workload '%s' is not ready, %d of %d", target, ready, desired), nil
+		}
+		log.Debug("workload is ready", log.String("workload", target.String()))
+	}
+	return true, "all workloads are ready", nil
+}
+
+// countReady returns numbers of ready and required replicas or Endpoints of the workload.
+func (wc *WorkloadChecker) countReady(ctx context.Context, target WorkloadRef) (int, int, error) {
+	switch target.Kind {
+	case WorkloadDeployment:
+		deployment, err := wc.client.GetDeployment(ctx, target.Namespace, target.Name)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(deployment.Status.ReadyReplicas), minReady(target, deployment.Spec.Replicas), nil
+	case WorkloadStatefulSet:
+		statefulSet, err := wc.client.GetStatefulSet(ctx, target.Namespace, target.Name)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(statefulSet.Status.ReadyReplicas), minReady(target, statefulSet.Spec.Replicas), nil
+	case WorkloadEndpoints:
+		slices, err := wc.client.GetServiceEndpoints(ctx, target.Namespace, target.Name)
+		if err != nil {
+			return 0, 0, err
+		}
+		return countReadyEndpoints(slices), target.MinReady, nil
+	default:
+		return 0, 0, errors.New("workload kind unsupported: " + target.Kind)
+	}
+}
+
+func minReady(target WorkloadRef, replicas *int32) int {
+	if target.MinReady != AllReplicas {
+		return target.MinReady
+	}
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
+
+// countReadyEndpoints counts ready backends of the Service. Dual-stack Services have a slice per address family,
+// so endpoints of the same pod are counted once, while endpoints without target are counted each.
+// Addresses of an endpoint are fungible, they stand for the same backend.
+func countReadyEndpoints(slices []discovery.EndpointSlice) int {
+	ready := 0
+	targets := make(map[string]bool)
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if target := endpoint.TargetRef; target != nil {
+				key := string(target.UID)
+				if key == "" {
+					key = target.Kind + "/" + target.Namespace + "/" + target.Name
+				}
+				if targets[key] {
+					continue
+				}
+				targets[key] = true
+			}
+			ready++
+		}
+	}
+	return ready
+}
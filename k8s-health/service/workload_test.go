@@ -0,0 +1,89 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/k8s-health/config"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	"testing"
+)
+
+func TestMinReadyDefaultsToDesiredReplicas(t *testing.T) {
+	// GIVEN
+	replicas := int32(3)
+
+	// WHEN
+	actual := minReady(WorkloadRef{MinReady: AllReplicas}, &replicas)
+
+	// THEN
+	require.Equal(t, 3, actual)
+}
+
+func TestMinReadyUsesConfigured(t *testing.T) {
+	// GIVEN
+	replicas := int32(3)
+
+	// WHEN
+	actual := minReady(WorkloadRef{MinReady: 1}, &replicas)
+
+	// THEN
+	require.Equal(t, 1, actual)
+}
+
+func TestCountReadyEndpointsSkipsNotReady(t *testing.T) {
+	// GIVEN
+	ready, notReady := true, false
+	slices := []discovery.EndpointSlice{
+		{Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Conditions: discovery.EndpointConditions{Ready: &ready}},
+			{Addresses: []string{"10.0.0.2"}, Conditions: discovery.EndpointConditions{Ready: &notReady}},
+		}},
+		{Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"10.0.0.3"}},
+		}},
+	}
+
+	// WHEN
+	actual := countReadyEndpoints(slices)
+
+	// THEN
+	require.Equal(t, 2, actual)
+}
+
+func TestCountReadyEndpointsOfDualStackOnce(t *testing.T) {
+	// GIVEN
+	pod0 := &core.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "coredns-0", UID: "0a1b"}
+	pod1 := &core.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "coredns-1", UID: "2c3d"}
+	slices := []discovery.EndpointSlice{
+		{AddressType: discovery.AddressTypeIPv4, Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, TargetRef: pod0},
+			{Addresses: []string{"10.0.0.2"}, TargetRef: pod1},
+		}},
+		{AddressType: discovery.AddressTypeIPv6, Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"fd00::1"}, TargetRef: pod0},
+			{Addresses: []string{"fd00::2"}, TargetRef: pod1},
+		}},
+	}
+
+	// WHEN
+	actual := countReadyEndpoints(slices)
+
+	// THEN
+	require.Equal(t, 2, actual)
+}
@@ -38,12 +38,22 @@ This file incorporates work covered by the following copyright and permission no
 package web
 
 import (
+	"encoding/json"
 	. "flakybit.net/psl/common"
 	"fmt"
 	log "log/slog"
 	"net/http"
 )
 
+const statusPath = "/status"
+
+type healthReport struct {
+	Healthy  bool           `json:"healthy"`
+	Reason   string         `json:"reason"`
+	Advisory string         `json:"advisory,omitempty"` // Failures of advisory checkers
+	Checkers []HealthStatus `json:"checkers"`
+}
+
 type Controller struct {
 	healthChecker HealthChecker
 }
@@ -55,11 +65,16 @@ func NewController(healthChecker HealthChecker) *Controller {
 }
 
 func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == statusPath {
+		c.serveStatus(w, r)
+		return
+	}
+
 	status := http.StatusOK
 	message := "Healthy"
 	if !c.healthChecker.IsHealthy() {
 		status = http.StatusPreconditionFailed
-		message = "Unhealthy"
+		message = "Unhealthy: " + c.healthChecker.Reason()
 	}
 
 	log.Debug("responding to health request",
@@ -75,3 +90,30 @@ func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			log.Any("error", err))
 	}
 }
+
+// serveStatus responds with results of each health checker in JSON.
+func (c *Controller) serveStatus(w http.ResponseWriter, r *http.Request) {
+	report := healthReport{Healthy: c.healthChecker.IsHealthy(), Reason: c.healthChecker.Reason()}
+	if reporter, ok := c.healthChecker.(HealthReporter); ok {
+		report.Advisory = reporter.AdvisoryReason()
+		report.Checkers = reporter.HealthStatuses()
+	}
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusPreconditionFailed
+	}
+
+	log.Debug("responding to status request",
+		log.String("client-ip", r.RemoteAddr),
+		log.Int("status", status))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Error("failed to respond to status request",
+			log.String("client-ip", r.RemoteAddr),
+			log.Int("status", status),
+			log.Any("error", err))
+	}
+}
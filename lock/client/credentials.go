@@ -0,0 +1,166 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TlsOptions configure verification of server certificate and client certificate for mTLS.
+type TlsOptions struct {
+	CaFile   string // CA bundle, system roots if empty
+	CertFile string // Client certificate
+	KeyFile  string // Client key
+	Insecure bool   // Whether to skip server certificate verification
+}
+
+// reloadingFile caches file content and reads it again once modification time or size changes,
+// so rotated tokens and certificates are picked up without restart.
+type reloadingFile struct {
+	mutex   sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+func (f *reloadingFile) read() ([]byte, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.data != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.data, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.data, f.modTime, f.size = data, info.ModTime(), info.Size()
+	return data, nil
+}
+
+// credentialFiles holds reloading files by path, they are shared by endpoints.
+type credentialFiles struct {
+	mutex sync.Mutex
+	files map[string]*reloadingFile
+}
+
+func (c *credentialFiles) read(path string) ([]byte, error) {
+	c.mutex.Lock()
+	file, found := c.files[path]
+	if !found {
+		file = &reloadingFile{path: path}
+		c.files[path] = file
+	}
+	c.mutex.Unlock()
+	return file.read()
+}
+
+// token reads bearer token from the file.
+func (c *credentialFiles) token(path string) (string, error) {
+	data, err := c.read(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file '%s' is empty", path)
+	}
+	return token, nil
+}
+
+// basicAuth reads "username:password" from the file.
+func (c *credentialFiles) basicAuth(path string) (string, string, error) {
+	data, err := c.read(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read basic auth: %w", err)
+	}
+	username, password, found := strings.Cut(strings.TrimSpace(string(data)), ":")
+	if !found {
+		return "", "", fmt.Errorf("basic auth file '%s' is not 'username:password'", path)
+	}
+	return username, password, nil
+}
+
+// tlsConfig creates TLS config which reads CA bundle and client certificate on each handshake,
+// so that rotated files are used for new connections.
+func (c *credentialFiles) tlsConfig(options TlsOptions) *tls.Config {
+	config := &tls.Config{}
+	if options.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certPem, err := c.read(options.CertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read client certificate: %w", err)
+			}
+			keyPem, err := c.read(options.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read client key: %w", err)
+			}
+			cert, err := tls.X509KeyPair(certPem, keyPem)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		}
+	}
+	switch {
+	case options.Insecure:
+		config.InsecureSkipVerify = true
+	case options.CaFile != "":
+		// verification is done against the reloaded bundle instead of the static RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return c.verifyWithCa(state, options.CaFile)
+		}
+	}
+	return config
+}
+
+func (c *credentialFiles) verifyWithCa(state tls.ConnectionState, caFile string) error {
+	caPem, err := c.read(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bytes.TrimSpace(caPem)) {
+		return fmt.Errorf("CA bundle '%s' has no certificates", caFile)
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       state.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
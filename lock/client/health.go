@@ -39,35 +39,69 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	. "flakybit.net/psl/lock/config"
 	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"io"
+	"io/fs"
 	log "log/slog"
 	"net"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const maxIdleConnections = 10
 
+// maxBodySize limits the part of HTTP response body read for matching.
+const maxBodySize = 1 << 20
+
 type HealthClient struct {
 	conf       Config
 	httpClient *http.Client
 	rawClient  *net.Dialer
+	resolver   *net.Resolver
+	files      *credentialFiles
+	tlsMutex   sync.Mutex
+	tlsClients map[TlsOptions]*http.Client // HTTP clients by TLS options of endpoints
+}
+
+// HttpCheck describes the HTTP request to send and the response to expect.
+type HttpCheck struct {
+	Url           string
+	Method        string      // GET if empty
+	Headers       http.Header // Request headers
+	Codes         []int       // Expected status codes, any 2XX if empty
+	Body          string      // Substring the response body must contain
+	JsonPath      string      // Dot-separated path which must be present in the JSON response body
+	JsonValue     string      // Value expected at JsonPath, any value except null and false if empty
+	TokenFile     string      // File with bearer token
+	BasicAuthFile string      // File with "username:password"
+	Tls           TlsOptions  // TLS options of HTTPS request
 }
 
+// NewHealthClient creates client without own timeouts, each check is limited by the deadline of its context.
 func NewHealthClient(conf Config) *HealthClient {
 	httpClient := &http.Client{
 		Transport: &http.Transport{MaxIdleConnsPerHost: maxIdleConnections},
-		Timeout:   conf.HealthCheck.Timeout,
 	}
-	dialer := &net.Dialer{
-		Timeout: conf.HealthCheck.Timeout,
-	}
-
+	dialer := &net.Dialer{}
 	client := &HealthClient{
-		conf,
-		httpClient,
-		dialer,
+		conf:       conf,
+		httpClient: httpClient,
+		rawClient:  dialer,
+		resolver:   net.DefaultResolver,
+		files:      &credentialFiles{files: map[string]*reloadingFile{}},
+		tlsClients: map[TlsOptions]*http.Client{},
 	}
 	log.Info("configured health client")
 	return client
@@ -83,26 +117,206 @@ func (c *HealthClient) CheckRaw(ctx context.Context, protocol, address string) (
 	if err != nil {
 		return false, err
 	}
+	return true, nil
+}
+
+// CheckGrpc calls standard gRPC health service, empty service name checks the server as a whole.
+func (c *HealthClient) CheckGrpc(ctx context.Context, address, service string) (bool, error) {
+	log.Debug("checking gRPC endpoint", log.String("address", address), log.String("service", service))
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return false, err
+	}
+	if response.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return false, fmt.Errorf("gRPC service status is %s", response.GetStatus())
+	}
+	return true, nil
+}
+
+// CheckDns resolves the name and requires at least minRecords addresses.
+func (c *HealthClient) CheckDns(ctx context.Context, name string, minRecords int) (bool, error) {
+	log.Debug("checking DNS endpoint", log.String("name", name))
+	addresses, err := c.resolver.LookupHost(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	if len(addresses) < minRecords {
+		return false, fmt.Errorf("name resolved to %d records, expected at least %d", len(addresses), minRecords)
+	}
+	return true, nil
+}
 
+// CheckTls performs TLS handshake, which verifies the certificate chain and validity period,
+// and requires the leaf certificate to stay valid for at least minValidity.
+func (c *HealthClient) CheckTls(ctx context.Context, address string, minValidity time.Duration, options TlsOptions) (bool, error) {
+	log.Debug("checking TLS endpoint", log.String("address", address))
+	dialer := &tls.Dialer{NetDialer: c.rawClient, Config: c.files.tlsConfig(options)}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return false, errors.New("server presented no certificate")
+	}
+	if expires := certificates[0].NotAfter; time.Until(expires) < minValidity {
+		return false, fmt.Errorf("certificate expires at %s", expires.Format(time.RFC3339))
+	}
 	return true, nil
 }
 
-func (c *HealthClient) CheckHttp(ctx context.Context, url string) (bool, error) {
-	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// CheckUnix requires the path to be a socket accepting connections.
+func (c *HealthClient) CheckUnix(ctx context.Context, path string) (bool, error) {
+	log.Debug("checking unix socket endpoint", log.String("path", path))
+	info, err := os.Stat(path)
 	if err != nil {
 		return false, err
 	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return false, fmt.Errorf("'%s' is not a socket", path)
+	}
+	return c.CheckRaw(ctx, "unix", path)
+}
+
+// CheckFile requires the path to exist, like a marker file on a hostPath volume.
+func (c *HealthClient) CheckFile(path string) (bool, error) {
+	log.Debug("checking file endpoint", log.String("path", path))
+	if _, err := os.Stat(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	log.Debug("checking HTTP endpoint", log.String("url", request.URL.String()))
-	response, err := c.httpClient.Do(request)
+func (c *HealthClient) CheckHttp(ctx context.Context, check HttpCheck) (bool, error) {
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	request, err := http.NewRequestWithContext(ctx, method, check.Url, nil)
 	if err != nil {
 		return false, err
 	}
-	_, err = io.ReadAll(response.Body)
-	err = response.Body.Close()
+	for name, values := range check.Headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if host := check.Headers.Get("Host"); host != "" {
+		request.Host = host
+	}
+	if check.TokenFile != "" {
+		token, err := c.files.token(check.TokenFile)
+		if err != nil {
+			return false, err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	if check.BasicAuthFile != "" {
+		username, password, err := c.files.basicAuth(check.BasicAuthFile)
+		if err != nil {
+			return false, err
+		}
+		request.SetBasicAuth(username, password)
+	}
+	log.Debug("checking HTTP endpoint", log.String("method", method), log.String("url", request.URL.String()))
+	response, err := c.clientFor(check.Tls).Do(request)
 	if err != nil {
 		return false, err
 	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxBodySize))
+	closeErr := response.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	if closeErr != nil {
+		return false, closeErr
+	}
+
+	if !isExpectedCode(response.StatusCode, check.Codes) {
+		return false, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	if check.Body != "" && !strings.Contains(string(body), check.Body) {
+		return false, fmt.Errorf("response body does not contain '%s'", check.Body)
+	}
+	if check.JsonPath != "" {
+		return matchJsonPath(body, check.JsonPath, check.JsonValue)
+	}
+	return true, nil
+}
 
-	return response.StatusCode >= 200 && response.StatusCode < 300, nil
+// clientFor returns HTTP client with the TLS options, clients are created once and reused for connection pooling.
+func (c *HealthClient) clientFor(options TlsOptions) *http.Client {
+	if options == (TlsOptions{}) {
+		return c.httpClient
+	}
+	c.tlsMutex.Lock()
+	defer c.tlsMutex.Unlock()
+	client, found := c.tlsClients[options]
+	if !found {
+		client = &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: maxIdleConnections,
+				TLSClientConfig:     c.files.tlsConfig(options),
+			},
+		}
+		c.tlsClients[options] = client
+	}
+	return client
+}
+
+func isExpectedCode(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	return slices.Contains(expected, code)
+}
+
+// matchJsonPath looks up dot-separated path in JSON body, numeric segments index arrays.
+// Without expected value, any value except null and false matches.
+func matchJsonPath(body []byte, path, expected string) (bool, error) {
+	var node any
+	if err := json.Unmarshal(body, &node); err != nil {
+		return false, fmt.Errorf("response body is not JSON: %w", err)
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch typed := node.(type) {
+		case map[string]any:
+			value, found := typed[segment]
+			if !found {
+				return false, fmt.Errorf("JSON path '%s' is not found", path)
+			}
+			node = value
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(typed) {
+				return false, fmt.Errorf("JSON path '%s' is not found", path)
+			}
+			node = typed[index]
+		default:
+			return false, fmt.Errorf("JSON path '%s' is not found", path)
+		}
+	}
+
+	if expected == "" {
+		if node == nil || node == false {
+			return false, fmt.Errorf("JSON path '%s' is %v", path, node)
+		}
+		return true, nil
+	}
+	actual := fmt.Sprint(node)
+	if str, ok := node.(string); ok {
+		actual = str
+	}
+	if actual != expected {
+		return false, fmt.Errorf("JSON path '%s' is '%s', expected '%s'", path, actual, expected)
+	}
+	return true, nil
 }
@@ -0,0 +1,316 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	. "flakybit.net/psl/lock/config"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = fmt.Fprint(w, body)
+	}))
+}
+
+func TestCheckHttpIf2xx(t *testing.T) {
+	// GIVEN
+	server := newTestServer(http.StatusNoContent, "")
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL})
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
+
+func TestCheckHttpIfUnexpectedCode(t *testing.T) {
+	// GIVEN
+	server := newTestServer(http.StatusOK, "")
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL, Codes: []int{204}})
+
+	// THEN
+	require.EqualError(t, err, "unexpected status code 200")
+	require.False(t, healthy)
+}
+
+func TestCheckHttpIfExpectedNon2xxCode(t *testing.T) {
+	// GIVEN
+	server := newTestServer(http.StatusUnauthorized, "")
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL, Codes: []int{401}})
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
+
+func TestCheckHttpIfBodyMissing(t *testing.T) {
+	// GIVEN
+	server := newTestServer(http.StatusOK, "starting")
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL, Body: "ok"})
+
+	// THEN
+	require.EqualError(t, err, "response body does not contain 'ok'")
+	require.False(t, healthy)
+}
+
+func TestMatchJsonPathIfPresent(t *testing.T) {
+	// GIVEN
+	body := []byte(`{"checks": [{"status": "UP"}], "ready": true}`)
+
+	// WHEN
+	byValue, _ := matchJsonPath(body, "checks.0.status", "UP")
+	byPresence, _ := matchJsonPath(body, "ready", "")
+
+	// THEN
+	require.True(t, byValue)
+	require.True(t, byPresence)
+}
+
+func TestMatchJsonPathIfDifferentValue(t *testing.T) {
+	// GIVEN
+	body := []byte(`{"status": "DOWN"}`)
+
+	// WHEN
+	healthy, err := matchJsonPath(body, "status", "UP")
+
+	// THEN
+	require.EqualError(t, err, "JSON path 'status' is 'DOWN', expected 'UP'")
+	require.False(t, healthy)
+}
+
+func TestMatchJsonPathIfFalse(t *testing.T) {
+	// GIVEN
+	body := []byte(`{"ready": false}`)
+
+	// WHEN
+	healthy, _ := matchJsonPath(body, "ready", "")
+
+	// THEN
+	require.False(t, healthy)
+}
+
+func TestMatchJsonPathIfMissing(t *testing.T) {
+	// GIVEN
+	body := []byte(`{"checks": []}`)
+
+	// WHEN
+	healthy, err := matchJsonPath(body, "checks.0.status", "")
+
+	// THEN
+	require.EqualError(t, err, "JSON path 'checks.0.status' is not found")
+	require.False(t, healthy)
+}
+
+func TestCheckGrpcIfServing(t *testing.T) {
+	// GIVEN
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("my.Service", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	serverHealthy, serverErr := client.CheckGrpc(context.Background(), listener.Addr().String(), "")
+	serviceHealthy, serviceErr := client.CheckGrpc(context.Background(), listener.Addr().String(), "my.Service")
+
+	// THEN
+	require.NoError(t, serverErr)
+	require.True(t, serverHealthy)
+	require.EqualError(t, serviceErr, "gRPC service status is NOT_SERVING")
+	require.False(t, serviceHealthy)
+}
+
+func TestCheckDnsIfTooFewRecords(t *testing.T) {
+	// GIVEN
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckDns(context.Background(), "localhost", 100)
+
+	// THEN
+	require.ErrorContains(t, err, "expected at least 100")
+	require.False(t, healthy)
+}
+
+func TestCheckTlsIfUntrusted(t *testing.T) {
+	// GIVEN
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckTls(context.Background(), server.Listener.Addr().String(), 0, TlsOptions{})
+
+	// THEN
+	require.Error(t, err)
+	require.False(t, healthy)
+}
+
+func TestCheckUnixIfListening(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckUnix(context.Background(), path)
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
+
+func TestCheckUnixIfRegularFile(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "test.sock")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckUnix(context.Background(), path)
+
+	// THEN
+	require.EqualError(t, err, "'"+path+"' is not a socket")
+	require.False(t, healthy)
+}
+
+func TestCheckFileIfMissing(t *testing.T) {
+	// GIVEN
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckFile(filepath.Join(t.TempDir(), "ready"))
+
+	// THEN
+	require.Error(t, err)
+	require.False(t, healthy)
+}
+
+func writeServerCa(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, caPem, 0o600))
+	return path
+}
+
+func TestCheckTlsIfCaFile(t *testing.T) {
+	// GIVEN
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	client := NewHealthClient(Config{})
+	options := TlsOptions{CaFile: writeServerCa(t, server)}
+
+	// WHEN
+	healthy, err := client.CheckTls(context.Background(), server.Listener.Addr().String(), 0, options)
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
+
+func TestCheckHttpIfInsecure(t *testing.T) {
+	// GIVEN
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL, Tls: TlsOptions{Insecure: true}})
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
+
+func TestCheckHttpReloadsToken(t *testing.T) {
+	// GIVEN
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer rotated-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("initial\n"), 0o600))
+	client := NewHealthClient(Config{})
+	check := HttpCheck{Url: server.URL, TokenFile: tokenFile}
+	initialHealthy, _ := client.CheckHttp(context.Background(), check)
+
+	// WHEN
+	require.NoError(t, os.WriteFile(tokenFile, []byte("rotated-token\n"), 0o600))
+	rotatedHealthy, err := client.CheckHttp(context.Background(), check)
+
+	// THEN
+	require.False(t, initialHealthy)
+	require.NoError(t, err)
+	require.True(t, rotatedHealthy)
+}
+
+func TestCheckHttpIfBasicAuth(t *testing.T) {
+	// GIVEN
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "elastic" || password != "secret:1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	authFile := filepath.Join(t.TempDir(), "auth")
+	require.NoError(t, os.WriteFile(authFile, []byte("elastic:secret:1"), 0o600))
+	client := NewHealthClient(Config{})
+
+	// WHEN
+	healthy, err := client.CheckHttp(context.Background(), HttpCheck{Url: server.URL, BasicAuthFile: authFile})
+
+	// THEN
+	require.NoError(t, err)
+	require.True(t, healthy)
+}
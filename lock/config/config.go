@@ -19,27 +19,46 @@ package config
 import (
 	"context"
 	"errors"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/common/server"
 	"github.com/sethvargo/go-envconfig"
 	log "log/slog"
 	"time"
 )
 
 type Config struct {
-	BindHost      string            `env:"PSL_BIND_HOST"`                  // Address to bind
-	BindPort      int               `env:"PSL_BIND_PORT, default=8080"`    // Port to bind
-	ParallelLocks int               `env:"PSL_PARALLEL_LOCKS, default=1"`  // Number of locks allowed to acquire simultaneously
-	LockDuration  time.Duration     `env:"PSL_LOCK_DURATION, default=10s"` // Default lock duration
+	BindHost      string            `env:"PSL_BIND_HOST"`                       // Address to bind
+	BindPort      int               `env:"PSL_BIND_PORT, default=8080"`         // Port to bind
+	ParallelLocks int               `env:"PSL_PARALLEL_LOCKS, default=1"`       // Number of locks allowed to acquire simultaneously
+	LockDuration  time.Duration     `env:"PSL_LOCK_DURATION, default=10s"`      // Default lock duration
+	MinDuration   time.Duration     `env:"PSL_LOCK_MIN_DURATION"`               // Shortest duration clients may request, no limit if zero
+	MaxDuration   time.Duration     `env:"PSL_LOCK_MAX_DURATION"`               // Longest duration clients may request, no limit if zero
+	MinPriority   int               `env:"PSL_LOCK_MIN_PRIORITY, default=-100"` // Lowest priority of lock requests, lower ones are raised to it
+	MaxPriority   int               `env:"PSL_LOCK_MAX_PRIORITY, default=100"`  // Highest priority of lock requests, higher ones are lowered to it
+	PolicyFile    string            `env:"PSL_POLICY_FILE"`                     // Admission policy file, see Policy
 	HealthCheck   HealthCheckConfig `env:", prefix=PSL_HC_"`
+	Tls           TlsConfig         `env:", prefix=PSL_TLS_"`
+	Auth          AuthConfig        `env:", prefix=PSL_AUTH_"`
+	Events        EventsConfig      `env:", prefix=PSL_EVENTS_"`
 }
 
 type HealthCheckConfig struct {
 	Enabled      bool          `env:"ENABLED, default=false"`
 	Endpoints    []string      `env:"ENDPOINTS"`                // List of endpoints to check before allow locking
+	Rule         string        `env:"RULE"`                     // Expression over endpoints, like "any(tcp://db-0:5432,tcp://db-1:5432)", instead of ENDPOINTS
 	PeriodOnFail time.Duration `env:"PERIOD_FAIL, default=10s"` // Period of health checks if previous failed
 	PeriodOnPass time.Duration `env:"PERIOD_PASS, default=60s"` // Period of health checks if previous succeeded
 	Timeout      time.Duration `env:"TIMEOUT, default=5s"`      // Timeout of health check requests
 }
 
+// EndpointRule returns the configured rule, or "all" of the endpoint list if there is no rule.
+func (c HealthCheckConfig) EndpointRule() (*Rule, error) {
+	if c.Rule == "" {
+		return AllOf(c.Endpoints), nil
+	}
+	return ParseRule(c.Rule)
+}
+
 func NewConfig(ctx context.Context) (Config, error) {
 	var conf Config
 	err := envconfig.Process(ctx, &conf)
@@ -63,6 +82,18 @@ func (c *Config) validate() error {
 	if c.LockDuration < 0 {
 		lockDurationError = errors.New("lock duration is lesser than 0")
 	}
+	var durationBoundsError error
+	if c.MinDuration < 0 {
+		durationBoundsError = errors.New("min lock duration is lesser than 0")
+	} else if c.MaxDuration < 0 {
+		durationBoundsError = errors.New("max lock duration is lesser than 0")
+	} else if c.MaxDuration > 0 && c.MinDuration > c.MaxDuration {
+		durationBoundsError = errors.New("min lock duration is greater than max lock duration")
+	}
+	var priorityBoundsError error
+	if c.MinPriority > c.MaxPriority {
+		priorityBoundsError = errors.New("min lock priority is greater than max lock priority")
+	}
 	var hcPeriodPassError error
 	if c.HealthCheck.PeriodOnPass < 0 {
 		hcPeriodPassError = errors.New("period on pass is lesser than 0")
@@ -72,8 +103,22 @@ func (c *Config) validate() error {
 		hcPeriodFailError = errors.New("period on fail is lesser than 0")
 	}
 	var hcEndpointsError error
-	if c.HealthCheck.Enabled && len(c.HealthCheck.Endpoints) == 0 {
-		hcEndpointsError = errors.New("endpoints health check is enabled, but endpoint list is empty")
+	if c.HealthCheck.Enabled && len(c.HealthCheck.Endpoints) == 0 && c.HealthCheck.Rule == "" {
+		hcEndpointsError = errors.New("endpoints health check is enabled, but endpoint list and rule are empty")
+	}
+	if len(c.HealthCheck.Endpoints) > 0 && c.HealthCheck.Rule != "" {
+		hcEndpointsError = errors.New("cannot specify both endpoint list and rule")
+	}
+	rule, err := c.HealthCheck.EndpointRule()
+	if err != nil {
+		hcEndpointsError = errors.Join(hcEndpointsError, err)
+	} else {
+		for _, endpoint := range rule.Endpoints() {
+			if _, err := ParseEndpoint(endpoint); err != nil {
+				hcEndpointsError = errors.Join(hcEndpointsError, err)
+			}
+		}
 	}
-	return errors.Join(parallelLocksError, lockDurationError, hcPeriodPassError, hcPeriodFailError, hcEndpointsError)
+	return errors.Join(parallelLocksError, lockDurationError, durationBoundsError, priorityBoundsError, hcPeriodPassError, hcPeriodFailError, hcEndpointsError,
+		c.Tls.Validate(), c.Auth.Validate(), c.Events.Validate())
 }
@@ -0,0 +1,499 @@
+/*
+This file is part of PSL (Pod Startup LockService).
+Copyright (c) 2024, The PSL (Pod Startup LockService) Authors
+
+PSL (Pod Startup LockService) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+
+This file incorporates work covered by the following copyright and permission notice:
+	Copyright (c) 2018, Oath Inc.
+
+	Permission is hereby granted, free of charge, to any person obtaining a copy
+	of this software and associated documentation files (the "Software"), to deal
+	in the Software without restriction, including without limitation the rights
+	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+	copies of the Software, and to permit persons to whom the Software is
+	furnished to do so, subject to the following conditions:
+
+	The above copyright notice and this permission notice shall be included in all
+	copies or substantial portions of the Software.
+
+	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+	SOFTWARE.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// optionPrefix marks query parameters consumed by PSL, they are stripped before the request is sent.
+const optionPrefix = "psl."
+
+// commonOptions are supported by endpoints of all protocols, others are protocol-specific.
+var commonOptions = []string{"timeout", "period-fail", "period-pass", "optional"}
+
+var (
+	ErrEndpointMalformed = errors.New("endpoint malformed")
+	ErrProtocolUnknown   = errors.New("protocol unknown")
+	ErrAddressMalformed  = errors.New("address malformed")
+	ErrOptionUnknown     = errors.New("option unknown")
+	ErrOptionUnsupported = errors.New("option not supported by protocol")
+	ErrOptionMalformed   = errors.New("option malformed")
+)
+
+// EndpointError describes why an endpoint cannot be parsed, Err is one of ErrEndpointMalformed and others above.
+type EndpointError struct {
+	Endpoint string
+	Err      error
+	Detail   string
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("endpoint '%s': %s: %s", e.Endpoint, e.Err, e.Detail)
+}
+
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
+
+type Endpoint interface {
+	Protocol() string
+	String() string
+	IsHttp() bool
+	Options() EndpointOptions
+}
+
+type RawEndpoint interface {
+	Endpoint
+	Address() string
+}
+
+type HttpEndpoint interface {
+	Endpoint
+	Url() string
+}
+
+type GrpcEndpoint interface {
+	RawEndpoint
+	Service() string
+}
+
+type DnsEndpoint interface {
+	Endpoint
+	Name() string
+}
+
+type PathEndpoint interface {
+	Endpoint
+	Path() string
+}
+
+// EndpointOptions tune the check of a single endpoint, they are given as "psl."-prefixed query parameters,
+// like "http://host:8080/health?psl.timeout=2s&psl.code=200&psl.optional=true".
+type EndpointOptions struct {
+	Timeout       time.Duration // Timeout of the check, 0 to use the default one
+	Optional      bool          // Whether failed endpoint is only reported and does not deny locking
+	PeriodOnFail  time.Duration // Period of checks if previous failed, 0 to use the default one
+	PeriodOnPass  time.Duration // Period of checks if previous succeeded, 0 to use the default one
+	Method        string        // HTTP method
+	Headers       http.Header   // HTTP request headers
+	Codes         []int         // Expected HTTP status codes, any 2XX if empty
+	Body          string        // Substring the HTTP response body must contain
+	JsonPath      string        // Dot-separated path which must be present in the JSON response body
+	JsonValue     string        // Value expected at JsonPath, any value except null and false if empty
+	MinRecords    int           // Number of DNS records the name must resolve to, 1 if 0
+	MinValidity   time.Duration // Time TLS certificate must stay valid for
+	TokenFile     string        // File with bearer token, like projected service account token
+	BasicAuthFile string        // File with "username:password" for basic auth
+	CaFile        string        // CA bundle to verify server certificate with, instead of system roots
+	CertFile      string        // Client certificate for mTLS
+	KeyFile       string        // Client key for mTLS
+	Insecure      bool          // Whether to skip server certificate verification, explicit opt-in
+}
+
+type EndpointData struct {
+	protocol string
+	options  EndpointOptions
+}
+
+type RawEndpointData struct {
+	EndpointData
+	address string
+}
+
+type HttpEndpointData struct {
+	EndpointData
+	url string
+}
+
+type GrpcEndpointData struct {
+	RawEndpointData
+	service string
+}
+
+type DnsEndpointData struct {
+	EndpointData
+	name string
+}
+
+type PathEndpointData struct {
+	EndpointData
+	path string
+}
+
+func (e *RawEndpointData) String() string {
+	return fmt.Sprintf("%s", e.address)
+}
+
+func (e *HttpEndpointData) String() string {
+	return fmt.Sprintf("%s", e.url)
+}
+
+func (e *GrpcEndpointData) String() string {
+	return fmt.Sprintf("%s://%s/%s", e.protocol, e.address, e.service)
+}
+
+func (e *DnsEndpointData) String() string {
+	return fmt.Sprintf("%s://%s", e.protocol, e.name)
+}
+
+func (e *PathEndpointData) String() string {
+	return fmt.Sprintf("%s://%s", e.protocol, e.path)
+}
+
+func (e *EndpointData) Protocol() string {
+	return e.protocol
+}
+
+func (e *EndpointData) IsHttp() bool {
+	return isHttp(e.Protocol())
+}
+
+func (e *EndpointData) Options() EndpointOptions {
+	return e.options
+}
+
+func isHttp(protocol string) bool {
+	return protocol == "http" || protocol == "https"
+}
+
+func (e *RawEndpointData) Address() string {
+	return e.address
+}
+
+func (e *HttpEndpointData) Url() string {
+	return e.url
+}
+
+func (e *GrpcEndpointData) Service() string {
+	return e.service
+}
+
+func (e *DnsEndpointData) Name() string {
+	return e.name
+}
+
+func (e *PathEndpointData) Path() string {
+	return e.path
+}
+
+// endpointParser creates endpoints of a protocol from URL with PSL options already stripped.
+type endpointParser struct {
+	options []string // Protocol-specific options, on top of commonOptions
+	parse   func(u *url.URL, options EndpointOptions) (Endpoint, error)
+}
+
+// tlsOptions configure verification of server certificate and client certificate for mTLS.
+var tlsOptions = []string{"ca-file", "cert-file", "key-file", "insecure"}
+
+var httpOptions = []string{"method", "header", "code", "body", "json", "token-file", "basic-auth-file"}
+
+var httpParser = endpointParser{httpOptions, parseHttp}
+var httpsParser = endpointParser{slices.Concat(httpOptions, tlsOptions), parseHttp}
+var rawParser = endpointParser{nil, parseRaw}
+
+// endpointParsers maps endpoint protocol to its parser.
+var endpointParsers = map[string]endpointParser{
+	"http":  httpParser,
+	"https": httpsParser,
+	"tcp":   rawParser,
+	"tcp4":  rawParser,
+	"tcp6":  rawParser,
+	"udp":   rawParser,
+	"udp4":  rawParser,
+	"udp6":  rawParser,
+	"grpc":  {nil, parseGrpc},
+	"dns":   {[]string{"min-records"}, parseDns},
+	"tls":   {slices.Concat([]string{"min-validity"}, tlsOptions), parseRaw},
+	"unix":  {nil, parsePath},
+	"file":  {nil, parsePath},
+}
+
+// EndpointProtocols lists supported endpoint protocols.
+func EndpointProtocols() []string {
+	return slices.Sorted(maps.Keys(endpointParsers))
+}
+
+// ParseEndpoint canonicalizes the endpoint through net/url and creates it with the parser of its protocol.
+func ParseEndpoint(str string) (Endpoint, error) {
+	u, err := url.Parse(str)
+	if err != nil || u.Scheme == "" || u.Opaque != "" || !strings.Contains(str, "://") {
+		return nil, &EndpointError{str, ErrEndpointMalformed, "expected 'protocol://address'"}
+	}
+	parser, found := endpointParsers[u.Scheme]
+	if !found {
+		return nil, &EndpointError{str, ErrProtocolUnknown, fmt.Sprintf("'%s'", u.Scheme)}
+	}
+
+	values, err := splitOptions(u)
+	if err != nil {
+		return nil, &EndpointError{str, ErrOptionMalformed, err.Error()}
+	}
+	options, err := parseOptions(str, u.Scheme, values, parser.options)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := parser.parse(u, options)
+	if err != nil {
+		return nil, &EndpointError{str, ErrAddressMalformed, err.Error()}
+	}
+	return endpoint, nil
+}
+
+func CreateRaw(protocol string, address string, options EndpointOptions) (RawEndpoint, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("expected 'host:port' or '[ipv6]:port', got '%s'", address)
+	}
+	if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+		return nil, fmt.Errorf("port is out of interval [1, 65535]: '%s'", port)
+	}
+	if host == "" {
+		return nil, fmt.Errorf("host is empty: '%s'", address)
+	}
+	return &RawEndpointData{EndpointData{protocol, options}, net.JoinHostPort(host, port)}, nil
+}
+
+func CreateHttp(protocol string, url string, options EndpointOptions) HttpEndpoint {
+	return &HttpEndpointData{EndpointData{protocol, options}, url}
+}
+
+// CreateGrpc creates endpoint from "host:port" address and service name, the service may be empty.
+func CreateGrpc(protocol string, address string, service string, options EndpointOptions) (GrpcEndpoint, error) {
+	raw, err := CreateRaw(protocol, address, options)
+	if err != nil {
+		return nil, err
+	}
+	return &GrpcEndpointData{*raw.(*RawEndpointData), service}, nil
+}
+
+func CreateDns(protocol string, name string, options EndpointOptions) (DnsEndpoint, error) {
+	if name == "" || strings.ContainsAny(name, ":[] ") {
+		return nil, fmt.Errorf("DNS name is malformed: '%s'", name)
+	}
+	if options.MinRecords == 0 {
+		options.MinRecords = 1
+	}
+	return &DnsEndpointData{EndpointData{protocol, options}, name}, nil
+}
+
+func CreatePath(protocol string, path string, options EndpointOptions) (PathEndpoint, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path is not absolute: '%s'", path)
+	}
+	return &PathEndpointData{EndpointData{protocol, options}, path}, nil
+}
+
+func parseHttp(u *url.URL, options EndpointOptions) (Endpoint, error) {
+	if u.Host == "" {
+		return nil, errors.New("host is empty")
+	}
+	return CreateHttp(u.Scheme, u.String(), options), nil
+}
+
+func parseRaw(u *url.URL, options EndpointOptions) (Endpoint, error) {
+	if err := requireHostOnly(u); err != nil {
+		return nil, err
+	}
+	return CreateRaw(u.Scheme, u.Host, options)
+}
+
+func parseGrpc(u *url.URL, options EndpointOptions) (Endpoint, error) {
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, errors.New("query and fragment are not supported")
+	}
+	return CreateGrpc(u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/"), options)
+}
+
+func parseDns(u *url.URL, options EndpointOptions) (Endpoint, error) {
+	if err := requireHostOnly(u); err != nil {
+		return nil, err
+	}
+	return CreateDns(u.Scheme, u.Host, options)
+}
+
+func parsePath(u *url.URL, options EndpointOptions) (Endpoint, error) {
+	if u.Host != "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("expected '%s:///absolute/path'", u.Scheme)
+	}
+	return CreatePath(u.Scheme, u.Path, options)
+}
+
+func requireHostOnly(u *url.URL) error {
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("path, query and fragment are not supported")
+	}
+	return nil
+}
+
+// splitOptions removes PSL options from the query of the URL and returns them, other query parameters are kept intact.
+func splitOptions(u *url.URL) (url.Values, error) {
+	options := url.Values{}
+	if u.RawQuery == "" {
+		return options, nil
+	}
+	var kept []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		if !strings.HasPrefix(param, optionPrefix) {
+			kept = append(kept, param)
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(param, optionPrefix), "=")
+		unescaped, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s'", param)
+		}
+		options.Add(key, unescaped)
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	return options, nil
+}
+
+func parseOptions(endpoint, protocol string, values url.Values, supported []string) (EndpointOptions, error) {
+	var options EndpointOptions
+	// sorted for stable error reporting
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		list := values[key]
+		value := list[len(list)-1]
+		var err error
+		switch key {
+		case "timeout":
+			options.Timeout, err = parsePositiveDuration(value)
+		case "period-fail":
+			options.PeriodOnFail, err = parsePositiveDuration(value)
+		case "period-pass":
+			options.PeriodOnPass, err = parsePositiveDuration(value)
+		case "optional":
+			options.Optional, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("not a boolean: '%s'", value)
+			}
+		case "method":
+			options.Method = strings.ToUpper(value)
+		case "header":
+			options.Headers, err = parseHeaders(list)
+		case "code":
+			options.Codes, err = parseCodes(list)
+		case "body":
+			options.Body = value
+		case "json":
+			options.JsonPath, options.JsonValue, _ = strings.Cut(value, "=")
+		case "min-records":
+			options.MinRecords, err = strconv.Atoi(value)
+			if err != nil || options.MinRecords < 1 {
+				err = fmt.Errorf("not a positive number: '%s'", value)
+			}
+		case "min-validity":
+			options.MinValidity, err = parsePositiveDuration(value)
+		case "token-file":
+			options.TokenFile = value
+		case "basic-auth-file":
+			options.BasicAuthFile = value
+		case "ca-file":
+			options.CaFile = value
+		case "cert-file":
+			options.CertFile = value
+		case "key-file":
+			options.KeyFile = value
+		case "insecure":
+			options.Insecure, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("not a boolean: '%s'", value)
+			}
+		default:
+			return options, &EndpointError{endpoint, ErrOptionUnknown, fmt.Sprintf("'%s'", key)}
+		}
+		if err != nil {
+			return options, &EndpointError{endpoint, ErrOptionMalformed, fmt.Sprintf("'%s' is %s", key, err)}
+		}
+		if !slices.Contains(commonOptions, key) && !slices.Contains(supported, key) {
+			return options, &EndpointError{endpoint, ErrOptionUnsupported, fmt.Sprintf("'%s' for '%s'", key, protocol)}
+		}
+	}
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return options, &EndpointError{endpoint, ErrOptionMalformed, "'cert-file' and 'key-file' must be given together"}
+	}
+	if options.Insecure && options.CaFile != "" {
+		return options, &EndpointError{endpoint, ErrOptionMalformed, "cannot specify both 'ca-file' and 'insecure'"}
+	}
+	if options.TokenFile != "" && options.BasicAuthFile != "" {
+		return options, &EndpointError{endpoint, ErrOptionMalformed, "cannot specify both 'token-file' and 'basic-auth-file'"}
+	}
+	return options, nil
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("not a positive duration: '%s'", value)
+	}
+	return duration, nil
+}
+
+func parseHeaders(list []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, header := range list {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("not 'Name:Value': '%s'", header)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+func parseCodes(list []string) ([]int, error) {
+	var codes []int
+	for _, str := range list {
+		code, err := strconv.Atoi(str)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("out of interval [100, 599]: '%s'", str)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
@@ -0,0 +1,365 @@
+/*
+This file is part of PSL (Pod Startup LockService).
+Copyright (c) 2024, The PSL (Pod Startup LockService) Authors
+
+PSL (Pod Startup LockService) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+
+This file incorporates work covered by the following copyright and permission notice:
+	Copyright (c) 2018, Oath Inc.
+
+	Permission is hereby granted, free of charge, to any person obtaining a copy
+	of this software and associated documentation files (the "Software"), to deal
+	in the Software without restriction, including without limitation the rights
+	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+	copies of the Software, and to permit persons to whom the Software is
+	furnished to do so, subject to the following conditions:
+
+	The above copyright notice and this permission notice shall be included in all
+	copies or substantial portions of the Software.
+
+	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+	SOFTWARE.
+*/
+
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewEndpointIfHttp(t *testing.T) {
+	// GIVEN
+	expectedProtocol := "http"
+
+	// WHEN
+	actual, err := ParseEndpoint("http://localhost:1234")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, expectedProtocol, actual.Protocol())
+	require.True(t, actual.IsHttp())
+}
+
+func TestNewEndpointIfHttps(t *testing.T) {
+	// GIVEN
+	expectedProtocol := "https"
+
+	// WHEN
+	actual, err := ParseEndpoint("https://localhost:1234")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, expectedProtocol, actual.Protocol())
+	require.True(t, actual.IsHttp())
+}
+
+func TestNewEndpointIfTcp(t *testing.T) {
+	// GIVEN
+	expectedProtocol := "tcp"
+
+	// WHEN
+	actual, err := ParseEndpoint("tcp://localhost:1234")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, expectedProtocol, actual.Protocol())
+	require.False(t, actual.IsHttp())
+}
+
+func TestNewEndpointIfInvalidString(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("localhost_1234")
+
+	// THEN
+	require.ErrorIs(t, err, ErrEndpointMalformed)
+	require.EqualError(t, err, "endpoint 'localhost_1234': endpoint malformed: expected 'protocol://address'")
+}
+
+func TestNewEndpointIfInvalidPort(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("localhost:abcd")
+
+	// THEN
+	require.ErrorIs(t, err, ErrEndpointMalformed)
+	require.EqualError(t, err, "endpoint 'localhost:abcd': endpoint malformed: expected 'protocol://address'")
+}
+
+func TestNewEndpointIfInvalidProtocol(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("localhost:1234")
+
+	// THEN
+	require.ErrorIs(t, err, ErrEndpointMalformed)
+	require.EqualError(t, err, "endpoint 'localhost:1234': endpoint malformed: expected 'protocol://address'")
+}
+
+func TestRawEndpointAddress(t *testing.T) {
+	// GIVEN
+	expectedAddress := "localhost:1234"
+
+	// WHEN
+	endpoint, err := ParseEndpoint("tcp://localhost:1234")
+	require.NoError(t, err)
+	actual := endpoint.(RawEndpoint)
+
+	// THEN
+	require.Equal(t, expectedAddress, actual.Address())
+}
+
+func TestHttpEndpointUrl(t *testing.T) {
+	// GIVEN
+	expectedUrl := "http://localhost:1234"
+
+	// WHEN
+	endpoint, err := ParseEndpoint("http://localhost:1234")
+	require.NoError(t, err)
+	actual := endpoint.(HttpEndpoint)
+
+	// THEN
+	require.Equal(t, expectedUrl, actual.Url())
+}
+
+func TestRawEndpointAddressNoPort(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("tcp://localhost")
+
+	// THEN
+	require.ErrorIs(t, err, ErrAddressMalformed)
+	require.EqualError(t, err, "endpoint 'tcp://localhost': address malformed: expected 'host:port' or '[ipv6]:port', got 'localhost'")
+}
+
+func TestHttpEndpointUrlNoPort(t *testing.T) {
+	// GIVEN
+	expectedUrl := "http://localhost"
+
+	// WHEN
+	endpoint, err := ParseEndpoint("http://localhost")
+	require.NoError(t, err)
+	actual := endpoint.(HttpEndpoint)
+
+	// THEN
+	require.Equal(t, expectedUrl, actual.Url())
+}
+
+func TestRawEndpointString(t *testing.T) {
+	// GIVEN
+	expected := "localhost:1234"
+
+	// WHEN
+	actual, err := ParseEndpoint("tcp://localhost:1234")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, expected, actual.String())
+}
+
+func TestHttpEndpointString(t *testing.T) {
+	// GIVEN
+	expected := "http://localhost:1234"
+
+	// WHEN
+	actual, err := ParseEndpoint("http://localhost:1234")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, expected, actual.String())
+}
+
+func TestHttpEndpointOptions(t *testing.T) {
+	// GIVEN
+	str := "http://localhost:1234/health?full=1&psl.timeout=2s&psl.method=head&psl.code=200&psl.code=204" +
+		"&psl.header=X-Probe:%20psl&psl.body=ok&psl.json=status.ready=true&psl.optional=true"
+
+	// WHEN
+	endpoint, err := ParseEndpoint(str)
+	require.NoError(t, err)
+	actual := endpoint.(HttpEndpoint)
+
+	// THEN
+	require.Equal(t, "http://localhost:1234/health?full=1", actual.Url())
+	require.Equal(t, EndpointOptions{
+		Timeout:   2 * time.Second,
+		Optional:  true,
+		Method:    "HEAD",
+		Headers:   http.Header{"X-Probe": {"psl"}},
+		Codes:     []int{200, 204},
+		Body:      "ok",
+		JsonPath:  "status.ready",
+		JsonValue: "true",
+	}, actual.Options())
+}
+
+func TestRawEndpointOptions(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("tcp://localhost:1234?psl.timeout=1s&psl.optional=true")
+	require.NoError(t, err)
+	actual := endpoint.(RawEndpoint)
+
+	// THEN
+	require.Equal(t, "localhost:1234", actual.Address())
+	require.Equal(t, EndpointOptions{Timeout: time.Second, Optional: true}, actual.Options())
+}
+
+func TestRawEndpointIfHttpOption(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("tcp://localhost:1234?psl.body=ok")
+
+	// THEN
+	require.ErrorIs(t, err, ErrOptionUnsupported)
+	require.EqualError(t, err, "endpoint 'tcp://localhost:1234?psl.body=ok': option not supported by protocol: 'body' for 'tcp'")
+}
+
+func TestEndpointIfUnknownOption(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("http://localhost:1234?psl.retries=3")
+
+	// THEN
+	require.ErrorIs(t, err, ErrOptionUnknown)
+	require.EqualError(t, err, "endpoint 'http://localhost:1234?psl.retries=3': option unknown: 'retries'")
+}
+
+func TestGrpcEndpoint(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("grpc://localhost:1234/my.Service")
+	require.NoError(t, err)
+	actual := endpoint.(GrpcEndpoint)
+
+	// THEN
+	require.Equal(t, "localhost:1234", actual.Address())
+	require.Equal(t, "my.Service", actual.Service())
+}
+
+func TestDnsEndpoint(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("dns://db.database.svc.cluster.local?psl.min-records=2")
+	require.NoError(t, err)
+	actual := endpoint.(DnsEndpoint)
+
+	// THEN
+	require.Equal(t, "db.database.svc.cluster.local", actual.Name())
+	require.Equal(t, 2, actual.Options().MinRecords)
+}
+
+func TestPathEndpoint(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("unix:///var/run/csi/csi.sock")
+	require.NoError(t, err)
+	actual := endpoint.(PathEndpoint)
+
+	// THEN
+	require.Equal(t, "/var/run/csi/csi.sock", actual.Path())
+	require.Equal(t, "unix:///var/run/csi/csi.sock", actual.String())
+}
+
+func TestEndpointIfUnknownProtocol(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("ftp://localhost:21")
+
+	// THEN
+	require.ErrorIs(t, err, ErrProtocolUnknown)
+	require.EqualError(t, err, "endpoint 'ftp://localhost:21': protocol unknown: 'ftp'")
+}
+
+func TestRawEndpointIfIpv6(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("tcp://[::1]:1234")
+	require.NoError(t, err)
+	actual := endpoint.(RawEndpoint)
+
+	// THEN
+	require.Equal(t, "[::1]:1234", actual.Address())
+}
+
+func TestRawEndpointIfPortOutOfRange(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("tcp://localhost:70000")
+
+	// THEN
+	require.ErrorIs(t, err, ErrAddressMalformed)
+}
+
+func TestHttpEndpointIsCanonical(t *testing.T) {
+	// GIVEN
+	// WHEN
+	endpoint, err := ParseEndpoint("HTTP://[::1]:8080/health?psl.timeout=1s")
+	require.NoError(t, err)
+
+	// THEN
+	require.Equal(t, "http", endpoint.Protocol())
+	require.Equal(t, "http://[::1]:8080/health", endpoint.(HttpEndpoint).Url())
+}
+
+func TestEndpointIfMalformedOption(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("tcp://localhost:1234?psl.timeout=soon")
+
+	// THEN
+	require.ErrorIs(t, err, ErrOptionMalformed)
+	require.EqualError(t, err, "endpoint 'tcp://localhost:1234?psl.timeout=soon': option malformed: 'timeout' is not a positive duration: 'soon'")
+}
+
+func TestValidateAggregatesEndpointErrors(t *testing.T) {
+	// GIVEN
+	conf := Config{ParallelLocks: 1, HealthCheck: HealthCheckConfig{
+		Endpoints: []string{"tcp://localhost", "http://localhost:8080", "ftp://localhost:21"},
+	}}
+
+	// WHEN
+	err := conf.validate()
+
+	// THEN
+	require.ErrorIs(t, err, ErrAddressMalformed)
+	require.ErrorIs(t, err, ErrProtocolUnknown)
+	var endpointError *EndpointError
+	require.ErrorAs(t, err, &endpointError)
+	require.Equal(t, "tcp://localhost", endpointError.Endpoint)
+}
+
+func TestHttpsEndpointIfCertWithoutKey(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("https://localhost:9200?psl.cert-file=/etc/tls/tls.crt")
+
+	// THEN
+	require.ErrorIs(t, err, ErrOptionMalformed)
+}
+
+func TestHttpEndpointIfTlsOption(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseEndpoint("http://localhost:9200?psl.insecure=true")
+
+	// THEN
+	require.ErrorIs(t, err, ErrOptionUnsupported)
+}
@@ -0,0 +1,185 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "log/slog"
+	"os"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Policy decides how lock requests of workloads are admitted, like:
+//
+//	rules:
+//	  - namespaces: [kube-system]
+//	    exempt: true
+//	  - selector: "app.kubernetes.io/part-of=batch"
+//	    maxDuration: 30s
+//	    pool: batch
+//	    priority: -10
+//	pools:
+//	  batch: 3
+type Policy struct {
+	Rules []PolicyRule   `json:"rules"`
+	Pools map[string]int `json:"pools"` // Number of locks allowed to acquire simultaneously by pool name
+}
+
+// PolicyRule matches workloads by namespace and labels, the first matching rule of the policy applies.
+// Labels are told by callers and cannot be verified, so they only narrow the match.
+// Exempt rules apply to callers of the listed namespaces once the namespace is verified by authentication.
+type PolicyRule struct {
+	Namespaces  []string      `json:"namespaces"`  // Namespaces to match, any if empty
+	Selector    string        `json:"selector"`    // Label selector to match, any labels if empty
+	Exempt      bool          `json:"exempt"`      // Whether to grant the lock immediately, without health checks and locking, to verified callers
+	MaxDuration meta.Duration `json:"maxDuration"` // Longest duration which may be requested, no limit if zero
+	Pool        string        `json:"pool"`        // Pool to acquire the lock in, the default one if empty
+	Priority    int           `json:"priority"`    // Priority of lock requests, unless authenticated callers request one
+
+	selector labels.Selector
+}
+
+// Caller identifies the workload requesting a lock.
+type Caller struct {
+	Namespace string
+	Pod       string
+	Labels    labels.Set
+	Verified  bool // Whether the namespace is of the authenticated service account rather than requested
+}
+
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	err := yaml.UnmarshalStrict(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy is malformed: %w", err)
+	}
+	var errs []error
+	for name, size := range policy.Pools {
+		if size < 1 {
+			errs = append(errs, fmt.Errorf("pool '%s' size is lesser than 1", name))
+		}
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		rule.selector, err = labels.Parse(rule.Selector)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d selector is malformed: %w", i+1, err))
+		}
+		if rule.Exempt && len(rule.Namespaces) == 0 {
+			errs = append(errs, fmt.Errorf("rule %d is exempt, but does not list namespaces", i+1))
+		}
+		if rule.MaxDuration.Duration < 0 {
+			errs = append(errs, fmt.Errorf("rule %d max duration is lesser than 0", i+1))
+		}
+		if _, found := policy.Pools[rule.Pool]; rule.Pool != "" && !found {
+			errs = append(errs, fmt.Errorf("rule %d pool '%s' is not defined", i+1, rule.Pool))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &policy, nil
+}
+
+// Match returns the first rule matching the caller, or nil if there is none.
+// Exempt rules are skipped unless the caller namespace is verified.
+func (p *Policy) Match(caller Caller) *PolicyRule {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Exempt && !caller.Verified {
+			continue
+		}
+		if len(rule.Namespaces) > 0 && !slices.Contains(rule.Namespaces, caller.Namespace) {
+			continue
+		}
+		if !rule.selector.Matches(caller.Labels) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// PolicyFile reads the policy again once file modification time or size changes.
+// Malformed changes are logged and the last valid policy is kept.
+type PolicyFile struct {
+	mutex   sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	policy  *Policy
+}
+
+// NewPolicyFile loads the policy from the file, or returns an empty policy if the path is empty.
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	file := &PolicyFile{path: path, policy: &Policy{}}
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy: %w", err)
+		}
+		err = file.load(info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Info("configured policy", log.String("path", path), log.Int("rules", len(file.policy.Rules)))
+	return file, nil
+}
+
+func (f *PolicyFile) Policy() *Policy {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.path == "" {
+		return f.policy
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		log.Error("failed to read policy, keeping the last one", log.String("path", f.path), log.Any("error", err))
+		return f.policy
+	}
+	if !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+		err = f.load(info)
+		if err != nil {
+			log.Error("failed to reload policy, keeping the last one", log.String("path", f.path), log.Any("error", err))
+		} else {
+			log.Info("reloaded policy", log.String("path", f.path), log.Int("rules", len(f.policy.Rules)))
+		}
+	}
+	return f.policy
+}
+
+func (f *PolicyFile) load(info os.FileInfo) error {
+	// remember the change even if it is malformed, not to log it on every request
+	f.modTime, f.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return err
+	}
+	f.policy = policy
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPolicy = `
+rules:
+  - namespaces: [kube-system]
+    exempt: true
+  - namespaces: [jobs]
+    selector: "tier=batch"
+    maxDuration: 30s
+    pool: batch
+pools:
+  batch: 3
+`
+
+func TestPolicyMatchesFirstRule(t *testing.T) {
+	// GIVEN
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	// WHEN
+	exempt := policy.Match(Caller{Namespace: "kube-system", Verified: true})
+	batch := policy.Match(Caller{Namespace: "jobs", Labels: labels.Set{"tier": "batch", "app": "report"}})
+
+	// THEN
+	require.True(t, exempt.Exempt)
+	require.Equal(t, "batch", batch.Pool)
+	require.Equal(t, 30*time.Second, batch.MaxDuration.Duration)
+}
+
+func TestPolicyMatchesNoRule(t *testing.T) {
+	// GIVEN
+	policy, _ := ParsePolicy([]byte(testPolicy))
+
+	// WHEN
+	otherNamespace := policy.Match(Caller{Namespace: "default", Labels: labels.Set{"tier": "batch"}})
+	otherLabels := policy.Match(Caller{Namespace: "jobs", Labels: labels.Set{"tier": "web"}})
+
+	// THEN
+	require.Nil(t, otherNamespace)
+	require.Nil(t, otherLabels)
+}
+
+func TestPolicySkipsExemptIfUnverified(t *testing.T) {
+	// GIVEN
+	policy, _ := ParsePolicy([]byte(testPolicy))
+
+	// WHEN
+	rule := policy.Match(Caller{Namespace: "kube-system"})
+
+	// THEN
+	require.Nil(t, rule)
+}
+
+func TestParsePolicyIfInvalid(t *testing.T) {
+	// GIVEN
+	data := `
+rules:
+  - selector: "tier in (batch"
+  - pool: missing
+    maxDuration: -1s
+  - exempt: true
+pools:
+  empty: 0
+`
+
+	// WHEN
+	_, err := ParsePolicy([]byte(data))
+
+	// THEN
+	require.ErrorContains(t, err, "pool 'empty' size is lesser than 1")
+	require.ErrorContains(t, err, "rule 1 selector is malformed")
+	require.ErrorContains(t, err, "rule 2 max duration is lesser than 0")
+	require.ErrorContains(t, err, "rule 2 pool 'missing' is not defined")
+	require.ErrorContains(t, err, "rule 3 is exempt, but does not list namespaces")
+}
+
+func TestParsePolicyIfUnknownField(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParsePolicy([]byte("rules:\n  - namespace: jobs\n"))
+
+	// THEN
+	require.ErrorContains(t, err, "policy is malformed")
+}
+
+func TestPolicyFileReloadsOnChange(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	file, err := NewPolicyFile(path)
+	require.NoError(t, err)
+
+	// WHEN
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - pool: batch\npools:\n  batch: 1\n"), 0o600))
+	policy := file.Policy()
+
+	// THEN
+	require.Equal(t, "batch", policy.Match(Caller{Namespace: "default"}).Pool)
+}
+
+func TestPolicyFileKeepsLastValidPolicy(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	file, _ := NewPolicyFile(path)
+
+	// WHEN
+	require.NoError(t, os.WriteFile(path, []byte("rules: ["), 0o600))
+	policy := file.Policy()
+
+	// THEN
+	require.Len(t, policy.Rules, 2)
+}
+
+func TestPolicyFileIfEmptyPath(t *testing.T) {
+	// GIVEN
+	// WHEN
+	file, err := NewPolicyFile("")
+
+	// THEN
+	require.NoError(t, err)
+	require.Nil(t, file.Policy().Match(Caller{Namespace: "default"}))
+}
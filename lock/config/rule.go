@@ -0,0 +1,177 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RuleEndpoint = "endpoint"
+	RuleAll      = "all"
+	RuleAny      = "any"
+	RuleAtLeast  = "atLeast"
+)
+
+// Rule is a node of boolean expression over endpoints, like "all(http://k8s-health:8080,any(tcp://db-0:5432,tcp://db-1:5432))".
+// Groups are "all(...)", "any(...)" and "atLeast(n,...)", leaves are endpoints.
+type Rule struct {
+	Kind     string  // One of RuleEndpoint, RuleAll, RuleAny, RuleAtLeast
+	Min      int     // Number of children which must be satisfied for RuleAtLeast
+	Children []*Rule // Children of a group
+	Endpoint string  // Endpoint of a leaf
+}
+
+// AllOf groups endpoints with "all", it is the rule of plain endpoint list.
+func AllOf(endpoints []string) *Rule {
+	rule := &Rule{Kind: RuleAll}
+	for _, endpoint := range endpoints {
+		rule.Children = append(rule.Children, &Rule{Kind: RuleEndpoint, Endpoint: endpoint})
+	}
+	return rule
+}
+
+// Endpoints returns distinct endpoints of the rule leaves in order of appearance.
+func (r *Rule) Endpoints() []string {
+	if r.Kind == RuleEndpoint {
+		return []string{r.Endpoint}
+	}
+	var endpoints []string
+	seen := map[string]bool{}
+	for _, child := range r.Children {
+		for _, endpoint := range child.Endpoints() {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return endpoints
+}
+
+func (r *Rule) String() string {
+	if r.Kind == RuleEndpoint {
+		return r.Endpoint
+	}
+	var args []string
+	if r.Kind == RuleAtLeast {
+		args = append(args, strconv.Itoa(r.Min))
+	}
+	for _, child := range r.Children {
+		args = append(args, child.String())
+	}
+	return r.Kind + "(" + strings.Join(args, ",") + ")"
+}
+
+// ParseRule parses rule expression. Endpoints in it must not contain "(", ")" or ",", escape them as in URL query.
+func ParseRule(str string) (*Rule, error) {
+	parser := &ruleParser{str, 0}
+	rule, err := parser.parse()
+	if err != nil {
+		return nil, fmt.Errorf("rule '%s' is malformed: %w", str, err)
+	}
+	if parser.pos < len(str) {
+		return nil, fmt.Errorf("rule '%s' is malformed: unexpected '%s' at %d", str, str[parser.pos:], parser.pos)
+	}
+	return rule, nil
+}
+
+type ruleParser struct {
+	str string
+	pos int
+}
+
+func (p *ruleParser) parse() (*Rule, error) {
+	p.skipSpaces()
+	token := p.token()
+	if token == "" {
+		return nil, fmt.Errorf("expected group or endpoint at %d", p.pos)
+	}
+	if p.peek() != '(' {
+		return &Rule{Kind: RuleEndpoint, Endpoint: token}, nil
+	}
+	if token != RuleAll && token != RuleAny && token != RuleAtLeast {
+		return nil, fmt.Errorf("unknown group '%s'", token)
+	}
+	p.pos++ // (
+
+	rule := &Rule{Kind: token}
+	if token == RuleAtLeast {
+		p.skipSpaces()
+		minToken := p.token()
+		minimum, err := strconv.Atoi(minToken)
+		if err != nil || minimum < 1 {
+			return nil, fmt.Errorf("atLeast requires positive number, got '%s'", minToken)
+		}
+		rule.Min = minimum
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		child, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		rule.Children = append(rule.Children, child)
+		p.skipSpaces()
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if rule.Kind == RuleAtLeast && rule.Min > len(rule.Children) {
+		return nil, fmt.Errorf("atLeast(%d) has only %d children", rule.Min, len(rule.Children))
+	}
+	return rule, nil
+}
+
+// token reads up to the next delimiter and trims spaces.
+func (p *ruleParser) token() string {
+	start := p.pos
+	for p.pos < len(p.str) && !strings.ContainsRune("(),", rune(p.str[p.pos])) {
+		p.pos++
+	}
+	return strings.TrimSpace(p.str[start:p.pos])
+}
+
+func (p *ruleParser) peek() byte {
+	if p.pos < len(p.str) {
+		return p.str[p.pos]
+	}
+	return 0
+}
+
+func (p *ruleParser) expect(char byte) error {
+	p.skipSpaces()
+	if p.peek() != char {
+		return fmt.Errorf("expected '%c' at %d", char, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *ruleParser) skipSpaces() {
+	for p.pos < len(p.str) && p.str[p.pos] == ' ' {
+		p.pos++
+	}
+}
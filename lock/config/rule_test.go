@@ -0,0 +1,92 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseRuleNested(t *testing.T) {
+	// GIVEN
+	str := "all( http://k8s-health:8080?psl.timeout=1s, any(tcp://db-0:5432, tcp://db-1:5432), atLeast(2, a:1, b:1, c:1) )"
+
+	// WHEN
+	rule, err := ParseRule(str)
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, "all(http://k8s-health:8080?psl.timeout=1s,any(tcp://db-0:5432,tcp://db-1:5432),atLeast(2,a:1,b:1,c:1))", rule.String())
+	require.Equal(t, 2, rule.Children[2].Min)
+}
+
+func TestParseRuleIfSingleEndpoint(t *testing.T) {
+	// GIVEN
+	// WHEN
+	rule, err := ParseRule("tcp://db:5432")
+
+	// THEN
+	require.NoError(t, err)
+	require.Equal(t, &Rule{Kind: RuleEndpoint, Endpoint: "tcp://db:5432"}, rule)
+}
+
+func TestRuleEndpointsAreDistinct(t *testing.T) {
+	// GIVEN
+	rule, _ := ParseRule("any(all(tcp://a:1,tcp://b:1),all(tcp://a:1,tcp://c:1))")
+
+	// WHEN
+	endpoints := rule.Endpoints()
+
+	// THEN
+	require.Equal(t, []string{"tcp://a:1", "tcp://b:1", "tcp://c:1"}, endpoints)
+}
+
+func TestParseRuleIfUnknownGroup(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseRule("most(tcp://a:1,tcp://b:1)")
+
+	// THEN
+	require.EqualError(t, err, "rule 'most(tcp://a:1,tcp://b:1)' is malformed: unknown group 'most'")
+}
+
+func TestParseRuleIfAtLeastTooMany(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseRule("atLeast(3,tcp://a:1,tcp://b:1)")
+
+	// THEN
+	require.EqualError(t, err, "rule 'atLeast(3,tcp://a:1,tcp://b:1)' is malformed: atLeast(3) has only 2 children")
+}
+
+func TestParseRuleIfUnclosed(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseRule("any(tcp://a:1,tcp://b:1")
+
+	// THEN
+	require.EqualError(t, err, "rule 'any(tcp://a:1,tcp://b:1' is malformed: expected ')' at 23")
+}
+
+func TestParseRuleIfTrailing(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, err := ParseRule("any(tcp://a:1),tcp://b:1")
+
+	// THEN
+	require.EqualError(t, err, "rule 'any(tcp://a:1),tcp://b:1' is malformed: unexpected ',tcp://b:1' at 14")
+}
@@ -40,6 +40,8 @@ package main
 
 import (
 	"context"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/common/server"
 	. "flakybit.net/psl/lock/client"
 	. "flakybit.net/psl/lock/config"
 	. "flakybit.net/psl/lock/service"
@@ -65,13 +67,42 @@ func main() {
 	}
 
 	healthClient := NewHealthClient(conf)
-	healthService := NewHealthCheckService(conf, healthClient)
+	healthService, err := NewHealthCheckService(conf, healthClient)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure health check service", log.Any("error", err))
+		panic(err)
+	}
 	go healthService.Run(ctx)
 
+	policy, err := NewPolicyFile(conf.PolicyFile)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure policy", log.Any("error", err))
+		panic(err)
+	}
+
+	var podEvents *PodEvents
+	if conf.Events.Enabled {
+		recorder, err := NewRecorder(nil, "psl-lock")
+		if err != nil {
+			log.ErrorContext(ctx, "failed to configure event recorder", log.Any("error", err))
+			panic(err)
+		}
+		podEvents = NewPodEvents(conf.Events, recorder)
+	}
+
 	lockService := NewLockService(conf)
-	controller := NewController(conf, healthService, lockService)
-	httpServer := NewHttpServer(conf, controller)
-	err = httpServer.ListenAndServe()
+	controller := NewController(conf, healthService, lockService, policy, podEvents)
+	authenticator, err := NewAuthenticator(conf.Auth, nil)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure authenticator", log.Any("error", err))
+		panic(err)
+	}
+	httpServer, err := NewHttpServer(conf, authenticator.Wrap(controller))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure http server", log.Any("error", err))
+		panic(err)
+	}
+	err = ListenAndServe(httpServer)
 	if err != nil {
 		log.ErrorContext(ctx, "failed to start http server", log.Any("error", err))
 		panic(err)
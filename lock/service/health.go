@@ -41,57 +41,126 @@ import (
 	"context"
 	. "flakybit.net/psl/lock/client"
 	. "flakybit.net/psl/lock/config"
+	"fmt"
 	log "log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// HealthCheckService checks each endpoint in its own loop and evaluates the rule over stored results,
+// so one slow or dead endpoint neither delays nor hides the others.
 type HealthCheckService struct {
 	conf      Config
 	client    *HealthClient
+	rule      *Rule
+	specs     []string // Endpoints as configured, rule leaves refer to them
 	endpoints []Endpoint
-	healthy   bool
+	statuses  []*endpointStatus
+	healthy   atomic.Bool // Latest evaluated status, to log its changes
 }
 
-func NewHealthCheckService(conf Config, client *HealthClient) *HealthCheckService {
+func NewHealthCheckService(conf Config, client *HealthClient) (*HealthCheckService, error) {
+	rule, err := conf.HealthCheck.EndpointRule()
+	if err != nil {
+		return nil, err
+	}
+	specs := rule.Endpoints()
 	var endpoints []Endpoint
-	for _, url := range conf.HealthCheck.Endpoints {
-		endpoints = append(endpoints, ParseEndpoint(url))
+	var statuses []*endpointStatus
+	for _, spec := range specs {
+		endpoint, err := ParseEndpoint(spec)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+		statuses = append(statuses, newEndpointStatus(endpoint))
 	}
 	checker := &HealthCheckService{
-		conf,
-		client,
-		endpoints,
-		false,
+		conf:      conf,
+		client:    client,
+		rule:      rule,
+		specs:     specs,
+		endpoints: endpoints,
+		statuses:  statuses,
+	}
+	log.Info("configured health check service", log.String("rule", rule.String()))
+	return checker, nil
+}
+
+func (hcs *HealthCheckService) Name() string {
+	return "endpoints"
+}
+
+// Reason explains the current health status.
+func (hcs *HealthCheckService) Reason() string {
+	if !hcs.conf.HealthCheck.Enabled {
+		return "health checks disabled"
 	}
-	log.Info("configured health check service")
-	return checker
+	_, reason := aggregate(hcs.rule, hcs.statusesBySpec())
+	return reason
 }
 
 func (hcs *HealthCheckService) IsHealthy() bool {
 	if !hcs.conf.HealthCheck.Enabled {
 		return true
 	}
-	return hcs.healthy
+	healthy, _ := aggregate(hcs.rule, hcs.statusesBySpec())
+	return healthy
+}
+
+// EndpointStatuses returns the latest results of all endpoints.
+func (hcs *HealthCheckService) EndpointStatuses() []EndpointStatus {
+	var statuses []EndpointStatus
+	for _, status := range hcs.statuses {
+		statuses = append(statuses, status.get())
+	}
+	return statuses
+}
+
+func (hcs *HealthCheckService) statusesBySpec() map[string]EndpointStatus {
+	statuses := map[string]EndpointStatus{}
+	for i, spec := range hcs.specs {
+		statuses[spec] = hcs.statuses[i].get()
+	}
+	return statuses
 }
 
 func (hcs *HealthCheckService) Run(ctx context.Context) {
-	ticker := time.NewTicker(hcs.conf.HealthCheck.PeriodOnFail)
+	for i, endpoint := range hcs.endpoints {
+		go hcs.runEndpoint(ctx, endpoint, hcs.statuses[i])
+	}
+}
+
+func (hcs *HealthCheckService) runEndpoint(ctx context.Context, endpoint Endpoint, status *endpointStatus) {
+	periodOnPass, periodOnFail := hcs.periods(endpoint)
+	ticker := time.NewTicker(periodOnFail)
 	defer ticker.Stop()
 
 	for {
-		checkStatus := hcs.checkAll(ctx, hcs.endpoints)
-		if checkStatus != hcs.healthy {
-			log.Info("health checks status changed",
-				log.Bool("old", hcs.healthy),
-				log.Bool("new", checkStatus))
+		started := time.Now()
+		checkStatus, err := hcs.check(ctx, endpoint)
+		latency := time.Since(started)
+
+		previous := status.get()
+		if checkStatus != previous.Healthy {
+			log.Info("endpoint health check status changed",
+				log.String("endpoint", endpoint.String()),
+				log.Bool("old", previous.Healthy),
+				log.Bool("new", checkStatus),
+				log.Any("error", err))
 			if checkStatus {
-				ticker.Reset(hcs.conf.HealthCheck.PeriodOnPass)
+				ticker.Reset(periodOnPass)
 			} else {
-				ticker.Reset(hcs.conf.HealthCheck.PeriodOnFail)
+				ticker.Reset(periodOnFail)
 			}
 		}
-		log.Debug("performed health checks", log.Bool("healthy", checkStatus))
-		hcs.healthy = checkStatus
+		log.Debug("performed endpoint health check",
+			log.String("endpoint", endpoint.String()),
+			log.Bool("healthy", checkStatus),
+			log.Duration("latency", latency))
+		status.update(checkStatus, err, latency, started)
+		hcs.logStatusChange()
 
 		select {
 		case <-ticker.C:
@@ -102,27 +171,68 @@ func (hcs *HealthCheckService) Run(ctx context.Context) {
 	}
 }
 
-func (hcs *HealthCheckService) checkAll(ctx context.Context, endpoints []Endpoint) bool {
-	for _, endpoint := range endpoints {
-		if !hcs.check(ctx, endpoint) {
-			return false
-		}
+// periods returns pass and fail periods of the endpoint, falling back to the configured defaults.
+func (hcs *HealthCheckService) periods(endpoint Endpoint) (time.Duration, time.Duration) {
+	periodOnPass, periodOnFail := hcs.conf.HealthCheck.PeriodOnPass, hcs.conf.HealthCheck.PeriodOnFail
+	if endpoint.Options().PeriodOnPass != 0 {
+		periodOnPass = endpoint.Options().PeriodOnPass
+	}
+	if endpoint.Options().PeriodOnFail != 0 {
+		periodOnFail = endpoint.Options().PeriodOnFail
 	}
-	return true
+	return periodOnPass, periodOnFail
 }
 
-func (hcs *HealthCheckService) check(ctx context.Context, endpoint Endpoint) bool {
-	if endpoint.IsHttp() {
-		healthy, err := hcs.client.CheckHttp(ctx, endpoint.(HttpEndpoint).Url())
-		if err != nil {
-			log.ErrorContext(ctx, "failed to check endpoint", log.Any("error", err))
+// logStatusChange logs changes of the overall status, including the unsatisfied branch of the rule.
+func (hcs *HealthCheckService) logStatusChange() {
+	healthy, reason := aggregate(hcs.rule, hcs.statusesBySpec())
+	if hcs.healthy.CompareAndSwap(!healthy, healthy) {
+		log.Info("health checks status changed",
+			log.Bool("old", !healthy),
+			log.Bool("new", healthy),
+			log.String("reason", reason))
+	}
+}
+
+// aggregate evaluates the rule over endpoint results, failed optional endpoints are only reported.
+// Reason lists unhealthy endpoints, prefixed with the unsatisfied group if there is one.
+func aggregate(rule *Rule, statuses map[string]EndpointStatus) (bool, string) {
+	healthy, unsatisfied := evaluate(rule, statuses)
+	var unhealthy []string
+	for _, spec := range rule.Endpoints() {
+		status := statuses[spec]
+		if status.Healthy {
+			continue
 		}
-		return healthy
-	} else {
-		healthy, err := hcs.client.CheckRaw(ctx, endpoint.Protocol(), endpoint.(RawEndpoint).Address())
-		if err != nil {
-			log.ErrorContext(ctx, "failed to check endpoint", log.Any("error", err))
+		reason := fmt.Sprintf("endpoint '%s' is unhealthy: %s", status.Endpoint, status.Error)
+		if status.Optional {
+			reason = "optional " + reason
 		}
-		return healthy
+		unhealthy = append(unhealthy, reason)
+	}
+	if len(unhealthy) == 0 {
+		return true, "all endpoints are healthy"
+	}
+	reason := strings.Join(unhealthy, "; ")
+	if !healthy && unsatisfied.Kind != RuleEndpoint {
+		reason = fmt.Sprintf("rule '%s' is not satisfied; %s", unsatisfied, reason)
+	}
+	return healthy, reason
+}
+
+func (hcs *HealthCheckService) check(ctx context.Context, endpoint Endpoint) (bool, error) {
+	timeout := endpoint.Options().Timeout
+	if timeout == 0 {
+		timeout = hcs.conf.HealthCheck.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	healthy, err := endpointChecks[endpoint.Protocol()](ctx, hcs.client, endpoint)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to check endpoint",
+			log.String("endpoint", endpoint.String()),
+			log.Any("error", err))
 	}
+	return healthy, err
 }
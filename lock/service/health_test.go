@@ -0,0 +1,184 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"errors"
+	. "flakybit.net/psl/lock/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// byEndpoint keys statuses by their endpoints, as if they were configured the same way.
+func byEndpoint(statuses ...EndpointStatus) map[string]EndpointStatus {
+	result := map[string]EndpointStatus{}
+	for _, status := range statuses {
+		result[status.Endpoint] = status
+	}
+	return result
+}
+
+func TestAggregateIfAllHealthy(t *testing.T) {
+	// GIVEN
+	rule := AllOf([]string{"tcp://db:5432", "http://cache:8080"})
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "tcp://db:5432", Healthy: true},
+		EndpointStatus{Endpoint: "http://cache:8080", Healthy: true},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.True(t, healthy)
+	require.Equal(t, "all endpoints are healthy", reason)
+}
+
+func TestAggregateReportsAllUnhealthy(t *testing.T) {
+	// GIVEN
+	rule := AllOf([]string{"tcp://db:5432", "http://cache:8080"})
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "tcp://db:5432", Error: "connection refused"},
+		EndpointStatus{Endpoint: "http://cache:8080", Error: "unexpected status code 503"},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "endpoint 'tcp://db:5432' is unhealthy: connection refused; "+
+		"endpoint 'http://cache:8080' is unhealthy: unexpected status code 503", reason)
+}
+
+func TestAggregateIfOptionalUnhealthy(t *testing.T) {
+	// GIVEN
+	rule := AllOf([]string{"tcp://db:5432", "http://cache:8080"})
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "tcp://db:5432", Healthy: true},
+		EndpointStatus{Endpoint: "http://cache:8080", Optional: true, Error: "timeout"},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.True(t, healthy)
+	require.Equal(t, "optional endpoint 'http://cache:8080' is unhealthy: timeout", reason)
+}
+
+func TestAggregateIfAnyHealthy(t *testing.T) {
+	// GIVEN
+	rule, _ := ParseRule("all(http://k8s-health:8080,any(tcp://db-0:5432,tcp://db-1:5432))")
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "http://k8s-health:8080", Healthy: true},
+		EndpointStatus{Endpoint: "tcp://db-0:5432", Error: "connection refused"},
+		EndpointStatus{Endpoint: "tcp://db-1:5432", Healthy: true},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.True(t, healthy)
+	require.Equal(t, "endpoint 'tcp://db-0:5432' is unhealthy: connection refused", reason)
+}
+
+func TestAggregateIfOptionalUnhealthyInAny(t *testing.T) {
+	// GIVEN
+	rule, _ := ParseRule("any(tcp://db-0:5432,tcp://db-1:5432?psl.optional=true)")
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "tcp://db-0:5432", Error: "connection refused"},
+		EndpointStatus{Endpoint: "tcp://db-1:5432?psl.optional=true", Optional: true, Error: "timeout"},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "rule 'any(tcp://db-0:5432,tcp://db-1:5432?psl.optional=true)' is not satisfied; "+
+		"endpoint 'tcp://db-0:5432' is unhealthy: connection refused; "+
+		"optional endpoint 'tcp://db-1:5432?psl.optional=true' is unhealthy: timeout", reason)
+}
+
+func TestAggregateIfOptionalUnhealthyInAllOfAny(t *testing.T) {
+	// GIVEN
+	rule, _ := ParseRule("any(all(tcp://db-0:5432,http://cache-0:8080?psl.optional=true),tcp://db-1:5432)")
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "tcp://db-0:5432", Healthy: true},
+		EndpointStatus{Endpoint: "http://cache-0:8080?psl.optional=true", Optional: true, Error: "timeout"},
+		EndpointStatus{Endpoint: "tcp://db-1:5432", Error: "connection refused"},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.True(t, healthy)
+	require.Equal(t, "optional endpoint 'http://cache-0:8080?psl.optional=true' is unhealthy: timeout; "+
+		"endpoint 'tcp://db-1:5432' is unhealthy: connection refused", reason)
+}
+
+func TestAggregateReportsUnsatisfiedGroup(t *testing.T) {
+	// GIVEN
+	rule, _ := ParseRule("all(http://k8s-health:8080,atLeast(2,tcp://c-0:6379,tcp://c-1:6379,tcp://c-2:6379))")
+	statuses := byEndpoint(
+		EndpointStatus{Endpoint: "http://k8s-health:8080", Healthy: true},
+		EndpointStatus{Endpoint: "tcp://c-0:6379", Healthy: true},
+		EndpointStatus{Endpoint: "tcp://c-1:6379", Error: "timeout"},
+		EndpointStatus{Endpoint: "tcp://c-2:6379", Error: "timeout"},
+	)
+
+	// WHEN
+	healthy, reason := aggregate(rule, statuses)
+
+	// THEN
+	require.False(t, healthy)
+	require.Equal(t, "rule 'atLeast(2,tcp://c-0:6379,tcp://c-1:6379,tcp://c-2:6379)' is not satisfied; "+
+		"endpoint 'tcp://c-1:6379' is unhealthy: timeout; endpoint 'tcp://c-2:6379' is unhealthy: timeout", reason)
+}
+
+func TestEndpointStatusKeepsLastSuccess(t *testing.T) {
+	// GIVEN
+	endpoint, _ := ParseEndpoint("tcp://localhost:1234")
+	status := newEndpointStatus(endpoint)
+	succeeded := time.Now()
+	status.update(true, nil, time.Millisecond, succeeded)
+
+	// WHEN
+	status.update(false, errors.New("connection refused"), time.Second, succeeded.Add(time.Minute))
+
+	// THEN
+	actual := status.get()
+	require.False(t, actual.Healthy)
+	require.Equal(t, "connection refused", actual.Error)
+	require.Equal(t, time.Second, actual.Latency)
+	require.Equal(t, succeeded, actual.LastSuccess)
+}
+
+func TestEndpointChecksCoverProtocols(t *testing.T) {
+	// GIVEN
+	protocols := EndpointProtocols()
+
+	// WHEN
+	// THEN
+	for _, protocol := range protocols {
+		require.Contains(t, endpointChecks, protocol)
+	}
+}
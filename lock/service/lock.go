@@ -38,54 +38,201 @@ This file incorporates work covered by the following copyright and permission no
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	. "flakybit.net/psl/lock/config"
 	log "log/slog"
+	"slices"
 	"sync"
 	"time"
 )
 
+// DefaultPool is the pool of locks which are not assigned to a pool by policy.
+const DefaultPool = ""
+
+// waiterTtl is how long denied requester stays queued without retrying, clients are expected to retry more often.
+const waiterTtl = 10 * time.Second
+
+// Waiter identifies lock requester, so that denied requesters are served by priority and then in order of arrival.
+type Waiter struct {
+	Id       string // Requester identity, like "namespace/pod", anonymous requesters are not queued
+	Priority int    // Higher is served first
+}
+
+type queuedWaiter struct {
+	Waiter
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// Lease is the acquired lock, holder may extend it with heartbeats and release it early by token.
+type Lease struct {
+	Token    string
+	Duration time.Duration
+	expires  time.Time
+}
+
 type LockService struct {
-	conf  Config
-	mutex sync.Mutex
-	locks []time.Time
+	conf    Config
+	mutex   sync.Mutex
+	leases  map[string][]*Lease                 // Live leases by pool
+	waiters map[string]map[string]*queuedWaiter // Denied requesters by pool and id
 }
 
 func NewLockService(conf Config) *LockService {
-	service := &LockService{conf: conf}
+	service := &LockService{conf: conf, leases: map[string][]*Lease{}, waiters: map[string]map[string]*queuedWaiter{}}
 	log.Info("configured lock service")
 	return service
 }
 
+// Acquire acquires the lock in the default pool.
 func (ls *LockService) Acquire(duration time.Duration) bool {
+	_, _, acquired := ls.AcquireInPool(DefaultPool, ls.conf.ParallelLocks, duration, Waiter{})
+	return acquired
+}
+
+// AcquireInPool acquires the lock if the pool has a free lock for the waiter,
+// i.e. there are more free locks than waiters queued ahead of it.
+// Denied waiter gets its 1-based position in the queue, anonymous one gets 0.
+func (ls *LockService) AcquireInPool(pool string, parallelLocks int, duration time.Duration, waiter Waiter) (*Lease, int, bool) {
 	ls.mutex.Lock()
 	defer ls.mutex.Unlock()
 
-	ls.removeExpired()
-	if len(ls.locks) < ls.conf.ParallelLocks {
-		ls.addNew(duration)
+	now := time.Now()
+	leases := removeExpired(ls.leases[pool], now)
+	ls.leases[pool] = leases
+	waiters := ls.queue(pool, waiter, now)
+	ahead := waitersAhead(waiters, waiter, now)
+	if parallelLocks-len(leases) > ahead {
+		lease := &Lease{Token: newToken(), Duration: duration, expires: now.Add(duration)}
+		ls.leases[pool] = append(leases, lease)
+		delete(waiters, waiter.Id)
 		log.Info("lock acquired",
+			log.String("pool", pool),
+			log.String("waiter", waiter.Id),
 			log.Int("duration", int(duration.Seconds())),
-			log.Int("locks", len(ls.locks)))
-		return true
+			log.Int("locks", len(ls.leases[pool])))
+		return lease, 0, true
+	}
+	if waiter.Id == "" {
+		return nil, 0, false
+	}
+	return nil, ahead + 1, false
+}
+
+// Heartbeat extends live lease by its duration, it returns false if the lease is expired or released.
+func (ls *LockService) Heartbeat(token string) bool {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	now := time.Now()
+	for _, leases := range ls.leases {
+		for _, lease := range leases {
+			if lease.Token == token && !isExpired(lease.expires, now) {
+				lease.expires = now.Add(lease.Duration)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Release frees live lease before it expires, it returns false if the lease is expired or released.
+func (ls *LockService) Release(token string) bool {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	now := time.Now()
+	for pool, leases := range ls.leases {
+		for i, lease := range leases {
+			if lease.Token == token && !isExpired(lease.expires, now) {
+				ls.leases[pool] = slices.Delete(leases, i, i+1)
+				log.Info("lock released",
+					log.String("pool", pool),
+					log.Int("locks", len(ls.leases[pool])))
+				return true
+			}
+		}
 	}
 	return false
 }
 
-func (ls *LockService) addNew(duration time.Duration) {
-	expireTime := time.Now().Add(duration)
-	ls.locks = append(ls.locks, expireTime)
+// PoolStatus is the current usage of a lock pool.
+type PoolStatus struct {
+	Name    string `json:"name"`
+	Locks   int    `json:"locks"`   // Number of live leases
+	Limit   int    `json:"limit"`   // Number of locks allowed to acquire simultaneously
+	Waiters int    `json:"waiters"` // Number of queued requesters
+}
+
+// PoolStatus returns usage of the pool having the limit of parallel locks.
+func (ls *LockService) PoolStatus(pool string, parallelLocks int) PoolStatus {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	now := time.Now()
+	ls.leases[pool] = removeExpired(ls.leases[pool], now)
+	waiters := ls.queue(pool, Waiter{}, now)
+	return PoolStatus{Name: pool, Locks: len(ls.leases[pool]), Limit: parallelLocks, Waiters: len(waiters)}
 }
 
-func (ls *LockService) removeExpired() {
-	var live []time.Time
-	for i := 0; i < len(ls.locks); i++ {
-		if !isExpired(ls.locks[i]) {
-			live = append(live, ls.locks[i])
+// queue removes stale waiters of the pool and refreshes the given one.
+func (ls *LockService) queue(pool string, waiter Waiter, now time.Time) map[string]*queuedWaiter {
+	waiters, found := ls.waiters[pool]
+	if !found {
+		waiters = map[string]*queuedWaiter{}
+		ls.waiters[pool] = waiters
+	}
+	for id, queued := range waiters {
+		if now.Sub(queued.lastSeen) > waiterTtl {
+			delete(waiters, id)
 		}
 	}
-	ls.locks = live
+	if waiter.Id != "" {
+		queued, found := waiters[waiter.Id]
+		if !found {
+			queued = &queuedWaiter{firstSeen: now}
+			waiters[waiter.Id] = queued
+		}
+		queued.Waiter, queued.lastSeen = waiter, now
+	}
+	return waiters
+}
+
+// waitersAhead counts waiters of higher priority, or of the same priority which arrived earlier.
+func waitersAhead(waiters map[string]*queuedWaiter, waiter Waiter, now time.Time) int {
+	firstSeen := now
+	if queued, found := waiters[waiter.Id]; found {
+		firstSeen = queued.firstSeen
+	}
+	ahead := 0
+	for id, queued := range waiters {
+		if id == waiter.Id {
+			continue
+		}
+		if queued.Priority > waiter.Priority || queued.Priority == waiter.Priority && queued.firstSeen.Before(firstSeen) {
+			ahead++
+		}
+	}
+	return ahead
+}
+
+func removeExpired(leases []*Lease, now time.Time) []*Lease {
+	var live []*Lease
+	for _, lease := range leases {
+		if !isExpired(lease.expires, now) {
+			live = append(live, lease)
+		}
+	}
+	return live
+}
+
+func isExpired(t time.Time, now time.Time) bool {
+	return now.After(t)
 }
 
-func isExpired(t time.Time) bool {
-	return time.Now().After(t)
+func newToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token) // never returns error
+	return hex.EncodeToString(token)
 }
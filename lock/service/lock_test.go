@@ -131,3 +131,136 @@ func TestAcquireMultipleIfReleased(t *testing.T) {
 	// THEN
 	require.True(t, success)
 }
+
+func TestAcquireInPoolIfDefaultExceeded(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lock.Acquire(duration)
+
+	// WHEN
+	_, _, success := lock.AcquireInPool("batch", 1, duration, Waiter{})
+
+	// THEN
+	require.True(t, success)
+}
+
+func TestAcquireInPoolIfExceed(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lock.AcquireInPool("batch", 2, duration, Waiter{})
+	lock.AcquireInPool("batch", 2, duration, Waiter{})
+
+	// WHEN
+	_, _, poolSuccess := lock.AcquireInPool("batch", 2, duration, Waiter{})
+	defaultSuccess := lock.Acquire(duration)
+
+	// THEN
+	require.False(t, poolSuccess)
+	require.True(t, defaultSuccess)
+}
+
+func TestAcquireInPoolServesHigherPriorityFirst(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{})
+	lock.AcquireInPool(DefaultPool, 1, time.Millisecond, Waiter{Id: "default/first"})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/low", Priority: 0})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/high", Priority: 10})
+	time.Sleep(2 * time.Millisecond)
+
+	// WHEN
+	_, _, lowSuccess := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/low", Priority: 0})
+	_, _, highSuccess := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/high", Priority: 10})
+
+	// THEN
+	require.False(t, lowSuccess)
+	require.True(t, highSuccess)
+}
+
+func TestAcquireInPoolServesSamePriorityInOrder(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{})
+	lock.AcquireInPool(DefaultPool, 1, time.Millisecond, Waiter{Id: "default/first"})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/second"})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/third"})
+	time.Sleep(2 * time.Millisecond)
+
+	// WHEN
+	_, _, thirdSuccess := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/third"})
+	_, _, secondSuccess := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/second"})
+
+	// THEN
+	require.False(t, thirdSuccess)
+	require.True(t, secondSuccess)
+}
+
+func TestAcquireInPoolTellsQueuePosition(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/first"})
+	lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/second"})
+
+	// WHEN
+	_, thirdPosition, _ := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/third"})
+	_, secondPosition, _ := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{Id: "default/second"})
+	_, anonymousPosition, _ := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{})
+
+	// THEN
+	require.Equal(t, 2, thirdPosition)
+	require.Equal(t, 1, secondPosition)
+	require.Equal(t, 0, anonymousPosition)
+}
+
+func TestReleaseFreesLock(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lease, _, _ := lock.AcquireInPool(DefaultPool, 1, duration, Waiter{})
+
+	// WHEN
+	released := lock.Release(lease.Token)
+	success := lock.Acquire(duration)
+
+	// THEN
+	require.True(t, released)
+	require.True(t, success)
+}
+
+func TestReleaseIfUnknownToken(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lock.Acquire(duration)
+
+	// WHEN
+	released := lock.Release("unknown")
+
+	// THEN
+	require.False(t, released)
+}
+
+func TestHeartbeatExtendsLease(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lease, _, _ := lock.AcquireInPool(DefaultPool, 1, 20*time.Millisecond, Waiter{})
+	time.Sleep(15 * time.Millisecond)
+
+	// WHEN
+	extended := lock.Heartbeat(lease.Token)
+	time.Sleep(15 * time.Millisecond)
+	success := lock.Acquire(duration)
+
+	// THEN
+	require.True(t, extended)
+	require.False(t, success)
+}
+
+func TestHeartbeatIfExpired(t *testing.T) {
+	// GIVEN
+	lock := NewLockService(Config{ParallelLocks: 1})
+	lease, _, _ := lock.AcquireInPool(DefaultPool, 1, 0, Waiter{})
+	time.Sleep(1 * time.Millisecond)
+
+	// WHEN
+	extended := lock.Heartbeat(lease.Token)
+
+	// THEN
+	require.False(t, extended)
+}
@@ -0,0 +1,94 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"context"
+	. "flakybit.net/psl/lock/client"
+	. "flakybit.net/psl/lock/config"
+)
+
+// endpointCheck checks an endpoint of a protocol, endpoints are created by ParseEndpoint.
+type endpointCheck func(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error)
+
+func checkHttp(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	options := endpoint.Options()
+	return client.CheckHttp(ctx, HttpCheck{
+		Url:           endpoint.(HttpEndpoint).Url(),
+		Method:        options.Method,
+		Headers:       options.Headers,
+		Codes:         options.Codes,
+		Body:          options.Body,
+		JsonPath:      options.JsonPath,
+		JsonValue:     options.JsonValue,
+		TokenFile:     options.TokenFile,
+		BasicAuthFile: options.BasicAuthFile,
+		Tls:           tlsOptions(options),
+	})
+}
+
+// checkRaw connects with net.Dialer, like "tcp" or "udp".
+func checkRaw(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	return client.CheckRaw(ctx, endpoint.Protocol(), endpoint.(RawEndpoint).Address())
+}
+
+func checkGrpc(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	grpcEndpoint := endpoint.(GrpcEndpoint)
+	return client.CheckGrpc(ctx, grpcEndpoint.Address(), grpcEndpoint.Service())
+}
+
+func checkDns(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	return client.CheckDns(ctx, endpoint.(DnsEndpoint).Name(), endpoint.Options().MinRecords)
+}
+
+func checkTls(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	return client.CheckTls(ctx, endpoint.(RawEndpoint).Address(), endpoint.Options().MinValidity, tlsOptions(endpoint.Options()))
+}
+
+func checkUnix(ctx context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	return client.CheckUnix(ctx, endpoint.(PathEndpoint).Path())
+}
+
+func checkFile(_ context.Context, client *HealthClient, endpoint Endpoint) (bool, error) {
+	return client.CheckFile(endpoint.(PathEndpoint).Path())
+}
+
+func tlsOptions(options EndpointOptions) TlsOptions {
+	return TlsOptions{
+		CaFile:   options.CaFile,
+		CertFile: options.CertFile,
+		KeyFile:  options.KeyFile,
+		Insecure: options.Insecure,
+	}
+}
+
+// endpointChecks maps endpoint protocol to its check, it must cover EndpointProtocols.
+var endpointChecks = map[string]endpointCheck{
+	"http":  checkHttp,
+	"https": checkHttp,
+	"tcp":   checkRaw,
+	"tcp4":  checkRaw,
+	"tcp6":  checkRaw,
+	"udp":   checkRaw,
+	"udp4":  checkRaw,
+	"udp6":  checkRaw,
+	"grpc":  checkGrpc,
+	"dns":   checkDns,
+	"tls":   checkTls,
+	"unix":  checkUnix,
+	"file":  checkFile,
+}
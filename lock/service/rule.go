@@ -0,0 +1,63 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/lock/config"
+)
+
+// evaluate checks the rule over endpoint results, optional endpoints are satisfied unless counted
+// directly by "any" or "atLeast" group, which must not be satisfied by unhealthy endpoints.
+// Optional endpoints of "all" group inside them are satisfied, as they are anywhere else in "all".
+// If the rule is not satisfied, it returns the deepest unsatisfied branch: failed child of "all",
+// or the whole "any" and "atLeast" group, since none of their children is to blame alone.
+func evaluate(rule *Rule, statuses map[string]EndpointStatus) (bool, *Rule) {
+	return evaluateCounted(rule, statuses, false)
+}
+
+func evaluateCounted(rule *Rule, statuses map[string]EndpointStatus, counted bool) (bool, *Rule) {
+	switch rule.Kind {
+	case RuleEndpoint:
+		status := statuses[rule.Endpoint]
+		if status.Healthy || (status.Optional && !counted) {
+			return true, nil
+		}
+		return false, rule
+	case RuleAll:
+		for _, child := range rule.Children {
+			if satisfied, unsatisfied := evaluateCounted(child, statuses, false); !satisfied {
+				return false, unsatisfied
+			}
+		}
+		return true, nil
+	default:
+		required := 1
+		if rule.Kind == RuleAtLeast {
+			required = rule.Min
+		}
+		satisfiedCount := 0
+		for _, child := range rule.Children {
+			if satisfied, _ := evaluateCounted(child, statuses, true); satisfied {
+				satisfiedCount++
+			}
+		}
+		if satisfiedCount >= required {
+			return true, nil
+		}
+		return false, rule
+	}
+}
@@ -0,0 +1,73 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	. "flakybit.net/psl/lock/config"
+	"sync"
+	"time"
+)
+
+const notCheckedError = "not checked yet"
+
+// EndpointStatus is the latest result of an endpoint check.
+type EndpointStatus struct {
+	Endpoint    string        `json:"endpoint"`
+	Optional    bool          `json:"optional"`
+	Healthy     bool          `json:"healthy"`
+	Error       string        `json:"error,omitempty"` // Failure of the latest check, empty if it succeeded
+	Latency     time.Duration `json:"latency"`         // Duration of the latest check
+	LastCheck   time.Time     `json:"lastCheck"`       // Start of the latest check, zero if not checked yet
+	LastSuccess time.Time     `json:"lastSuccess"`     // Start of the latest successful check, zero if never succeeded
+}
+
+// endpointStatus holds the latest result of an endpoint check, safe for concurrent use.
+type endpointStatus struct {
+	mutex  sync.RWMutex
+	status EndpointStatus
+}
+
+func newEndpointStatus(endpoint Endpoint) *endpointStatus {
+	return &endpointStatus{status: EndpointStatus{
+		Endpoint: endpoint.String(),
+		Optional: endpoint.Options().Optional,
+		Error:    notCheckedError,
+	}}
+}
+
+func (s *endpointStatus) get() EndpointStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.status
+}
+
+func (s *endpointStatus) update(healthy bool, err error, latency time.Duration, checked time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status.Healthy = healthy
+	s.status.Error = ""
+	if err != nil {
+		s.status.Error = err.Error()
+	} else if !healthy {
+		s.status.Error = "check failed"
+	}
+	s.status.Latency = latency
+	s.status.LastCheck = checked
+	if healthy {
+		s.status.LastSuccess = checked
+	}
+}
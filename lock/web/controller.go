@@ -38,54 +38,206 @@ This file incorporates work covered by the following copyright and permission no
 package web
 
 import (
+	"encoding/json"
+	"errors"
 	. "flakybit.net/psl/common"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/common/server"
 	. "flakybit.net/psl/lock/config"
 	. "flakybit.net/psl/lock/service"
 	"fmt"
+	"k8s.io/apimachinery/pkg/labels"
 	log "log/slog"
+	"maps"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"time"
 )
 
+// Lease token and duration are returned in headers of successful lock response, and the reason in headers
+// of denied one, to keep its body compatible.
+const (
+	HeaderLeaseToken    = "PSL-Lease-Token"
+	HeaderLeaseDuration = "PSL-Lease-Duration"
+	HeaderDenialReason  = "PSL-Denial-Reason"
+	HeaderQueuePosition = "PSL-Queue-Position"
+)
+
 type Controller struct {
 	conf          Config
 	healthChecker HealthChecker
 	lockService   *LockService
+	policy        *PolicyFile
+	events        *PodEvents // Nil if events are disabled
+	mux           *http.ServeMux
 }
 
-func NewController(conf Config, healthChecker HealthChecker, lockService *LockService) *Controller {
-	controller := &Controller{conf, healthChecker, lockService}
+func NewController(conf Config, healthChecker HealthChecker, lockService *LockService, policy *PolicyFile, events *PodEvents) *Controller {
+	controller := &Controller{conf, healthChecker, lockService, policy, events, http.NewServeMux()}
+	controller.mux.HandleFunc("POST /heartbeat", controller.heartbeat)
+	controller.mux.HandleFunc("POST /release", controller.release)
+	controller.mux.HandleFunc("GET /status", controller.status)
+	controller.mux.HandleFunc("/", controller.acquire)
 	log.Info("configured web controller")
 	return controller
 }
 
 func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// lockReport is the state of the lock service.
+type lockReport struct {
+	Healthy   bool             `json:"healthy"`
+	Reason    string           `json:"reason"`
+	Pools     []PoolStatus     `json:"pools"`
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"`
+}
+
+// endpointReporter exposes results of endpoint checks.
+type endpointReporter interface {
+	EndpointStatuses() []EndpointStatus
+}
+
+// status responds with health and usage of lock pools in JSON.
+func (c *Controller) status(w http.ResponseWriter, r *http.Request) {
+	report := lockReport{
+		Healthy: c.healthChecker.IsHealthy(),
+		Reason:  c.healthChecker.Reason(),
+		Pools:   []PoolStatus{c.lockService.PoolStatus(DefaultPool, c.conf.ParallelLocks)},
+	}
+	policy := c.policy.Policy()
+	for _, pool := range slices.Sorted(maps.Keys(policy.Pools)) {
+		report.Pools = append(report.Pools, c.lockService.PoolStatus(pool, policy.Pools[pool]))
+	}
+	if reporter, ok := c.healthChecker.(endpointReporter); ok {
+		report.Endpoints = reporter.EndpointStatuses()
+	}
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusPreconditionFailed
+	}
+
+	log.Debug("responding to status request",
+		log.String("client-ip", r.RemoteAddr),
+		log.Int("status", status))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Error("failed to respond to status request",
+			log.String("client-ip", r.RemoteAddr),
+			log.Int("status", status),
+			log.Any("error", err))
+	}
+}
+
+// heartbeat extends the lease given by "token" parameter.
+func (c *Controller) heartbeat(w http.ResponseWriter, r *http.Request) {
+	c.respondToLease(w, r, "heartbeat", c.lockService.Heartbeat, "Lease extended")
+}
+
+// release frees the lease given by "token" parameter.
+func (c *Controller) release(w http.ResponseWriter, r *http.Request) {
+	c.respondToLease(w, r, "release", c.lockService.Release, "Lease released")
+}
+
+func (c *Controller) respondToLease(w http.ResponseWriter, r *http.Request, action string, apply func(string) bool, message string) {
+	status := http.StatusOK
+	token := r.FormValue("token")
+	if token == "" {
+		status, message = http.StatusBadRequest, "token is missing"
+	} else if !apply(token) {
+		status, message = http.StatusNotFound, "lease is expired or released"
+	}
+
+	log.Info("responding to "+action+" request",
+		log.String("client-ip", r.RemoteAddr),
+		log.Int("status", status))
+
+	w.WriteHeader(status)
+	_, err := fmt.Fprint(w, message)
+	if err != nil {
+		log.Error("failed to respond to "+action+" request",
+			log.String("client-ip", r.RemoteAddr),
+			log.Int("status", status),
+			log.Any("error", err))
+	}
+}
+
+func (c *Controller) acquire(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusOK
 	message := "Lock acquired"
 
-	if c.healthChecker.IsHealthy() {
-		duration := c.getRequestedDuration(r.URL.Query())
+	caller := getCaller(r)
+	policy := c.policy.Policy()
+	rule := policy.Match(caller)
+	request, err := c.getLockRequest(r.URL.Query(), policy)
+	if err != nil {
+		status = http.StatusBadRequest
+		message = err.Error()
+	} else if !mayRequestPool(caller, rule, request.pool) {
+		status = http.StatusForbidden
+		message = fmt.Sprintf("pool '%s' may not be requested", request.pool)
+	} else if rule != nil && rule.Exempt {
+		message = "Exempt"
+	} else if c.healthChecker.IsHealthy() {
+		duration := request.duration
 		if duration == 0 {
 			duration = c.conf.LockDuration
 		}
-		acquired := c.lockService.Acquire(duration)
-		if !acquired {
+		pool, parallelLocks := DefaultPool, c.conf.ParallelLocks
+		if request.pool != "" {
+			pool, parallelLocks = request.pool, policy.Pools[request.pool]
+		}
+		if rule != nil {
+			if rule.MaxDuration.Duration > 0 && duration > rule.MaxDuration.Duration {
+				log.Info("capped requested duration by policy",
+					log.String("namespace", caller.Namespace),
+					log.String("duration", duration.String()),
+					log.String("max-duration", rule.MaxDuration.Duration.String()))
+				duration = rule.MaxDuration.Duration
+			}
+			if rule.Pool != "" {
+				pool, parallelLocks = rule.Pool, policy.Pools[rule.Pool]
+			}
+		}
+		priority := c.getPriority(caller, rule, request)
+		lease, position, acquired := c.lockService.AcquireInPool(pool, parallelLocks, duration, waiterOf(caller, priority))
+		if acquired {
+			w.Header().Set(HeaderLeaseToken, lease.Token)
+			w.Header().Set(HeaderLeaseDuration, lease.Duration.String())
+			c.events.Acquired(r.Context(), podRefOf(r, caller))
+		} else {
 			status = http.StatusLocked
 			message = "Locked"
+			reason := denialReason(pool)
+			w.Header().Set(HeaderDenialReason, reason)
+			if position > 0 {
+				w.Header().Set(HeaderQueuePosition, strconv.Itoa(position))
+			}
+			c.events.Throttled(r.Context(), podRefOf(r, caller), reason, position)
 		}
 	} else {
 		status = http.StatusLocked
 		message = "Locked"
+		reason := "dependent endpoints are unhealthy: " + c.healthChecker.Reason()
+		w.Header().Set(HeaderDenialReason, reason)
+		c.events.Throttled(r.Context(), podRefOf(r, caller), reason, 0)
 	}
 
 	log.Info("responding to lock request",
 		log.String("client-ip", r.RemoteAddr),
+		log.String("namespace", caller.Namespace),
+		log.String("pod", caller.Pod),
 		log.Int("status", status))
 
 	w.WriteHeader(status)
-	_, err := fmt.Fprint(w, message)
+	_, err = fmt.Fprint(w, message)
 	if err != nil {
 		log.Error("failed to respond to lock request",
 			log.String("client-ip", r.RemoteAddr),
@@ -94,17 +246,141 @@ func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (c *Controller) getRequestedDuration(values url.Values) time.Duration {
-	durationStr := values.Get("duration")
-	if durationStr == "" {
-		return 0
+// denialReason explains the lock in the pool is not acquired.
+func denialReason(pool string) string {
+	if pool == DefaultPool {
+		return "no free lock"
 	}
-	duration, err := strconv.Atoi(durationStr)
+	return fmt.Sprintf("no free lock in pool '%s'", pool)
+}
+
+// podRefOf identifies the pod of the caller for events, UID is given by the "uid" parameter.
+func podRefOf(r *http.Request, caller Caller) PodRef {
+	return PodRef{Namespace: caller.Namespace, Name: caller.Pod, Uid: r.URL.Query().Get("uid")}
+}
+
+// getCaller identifies the caller by request parameters.
+// Namespace of the service account authenticated the request takes precedence over the requested one.
+func getCaller(r *http.Request) Caller {
+	values := r.URL.Query()
+	caller := Caller{Namespace: values.Get("namespace"), Pod: values.Get("pod")}
+	podLabels, err := labels.ConvertSelectorToLabelsMap(values.Get("labels"))
 	if err != nil {
-		log.Error("invalid requested duration",
-			log.String("duration", durationStr),
+		log.Error("invalid caller labels",
+			log.String("labels", values.Get("labels")),
 			log.Any("error", err))
-		return 0
+	} else {
+		caller.Labels = podLabels
+	}
+	if namespace, _, found := ServiceAccountOf(r.Context()); found {
+		if caller.Namespace != "" && caller.Namespace != namespace {
+			log.Warn("requested namespace differs from authenticated one",
+				log.String("namespace", caller.Namespace),
+				log.String("authenticated-namespace", namespace))
+		}
+		caller.Namespace, caller.Verified = namespace, true
+	}
+	return caller
+}
+
+// mayRequestPool permits requesting the pool the matching rule assigns, or any pool to callers verified
+// by authentication, so that others can't escape throttling of their pool.
+func mayRequestPool(caller Caller, rule *PolicyRule, pool string) bool {
+	return pool == "" || caller.Verified || rule != nil && rule.Pool == pool
+}
+
+// getPriority takes the priority requested by callers verified by authentication, or assigned by the matching rule,
+// bounded by the configured range, so that others can't jump the queue.
+func (c *Controller) getPriority(caller Caller, rule *PolicyRule, request lockRequest) int {
+	priority := 0
+	if rule != nil {
+		priority = rule.Priority
+	}
+	if request.priorityRequested {
+		if caller.Verified {
+			priority = request.priority
+		} else {
+			log.Info("ignored priority requested by unauthenticated caller",
+				log.String("namespace", caller.Namespace),
+				log.String("pod", caller.Pod),
+				log.Int("priority", request.priority))
+		}
+	}
+	return min(max(priority, c.conf.MinPriority), c.conf.MaxPriority)
+}
+
+// waiterOf identifies the caller by pod, callers not telling their pod are anonymous.
+func waiterOf(caller Caller, priority int) Waiter {
+	waiter := Waiter{Priority: priority}
+	if caller.Pod != "" {
+		waiter.Id = caller.Namespace + "/" + caller.Pod
+	}
+	return waiter
+}
+
+// lockRequest holds parameters requested by the caller.
+type lockRequest struct {
+	duration          time.Duration // Zero if not requested
+	pool              string        // Empty if not requested, pool assigned by policy takes precedence, see mayRequestPool
+	priority          int
+	priorityRequested bool // Priority is honoured for authenticated callers only, see getPriority
+}
+
+func (c *Controller) getLockRequest(values url.Values, policy *Policy) (lockRequest, error) {
+	duration, err := c.getRequestedDuration(values)
+	if err != nil {
+		return lockRequest{}, err
+	}
+	request := lockRequest{duration: duration, pool: values.Get("pool")}
+	if _, found := policy.Pools[request.pool]; request.pool != "" && !found {
+		return request, fmt.Errorf("pool '%s' is not defined", request.pool)
+	}
+	if priorityStr := values.Get("priority"); priorityStr != "" {
+		request.priority, err = strconv.Atoi(priorityStr)
+		request.priorityRequested = true
+		if err != nil {
+			return request, fmt.Errorf("priority '%s' is not integer", priorityStr)
+		}
+	}
+	return request, nil
+}
+
+// getRequestedDuration parses duration in Go syntax, like "1m30s", or in seconds, like "90".
+// It returns zero if no duration is requested.
+func (c *Controller) getRequestedDuration(values url.Values) (time.Duration, error) {
+	durationStr := values.Get("duration")
+	if durationStr == "" {
+		return 0, nil
+	}
+	var duration time.Duration
+	if seconds, err := strconv.ParseInt(durationStr, 10, 64); err == nil {
+		// range-check before multiplying, not to wrap around
+		if seconds <= 0 {
+			return 0, fmt.Errorf("duration '%s' is not positive", durationStr)
+		}
+		if c.conf.MaxDuration > 0 && seconds > int64(c.conf.MaxDuration/time.Second) {
+			return 0, fmt.Errorf("duration '%s' is longer than %s", durationStr, c.conf.MaxDuration)
+		}
+		if seconds > math.MaxInt64/int64(time.Second) {
+			return 0, fmt.Errorf("duration '%s' is out of range", durationStr)
+		}
+		duration = time.Duration(seconds) * time.Second
+	} else if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("duration '%s' is out of range", durationStr)
+	} else {
+		duration, err = time.ParseDuration(durationStr)
+		if err != nil {
+			return 0, fmt.Errorf("duration '%s' is neither Go duration like '1m30s' nor number of seconds", durationStr)
+		}
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration '%s' is not positive", durationStr)
+	}
+	if c.conf.MinDuration > 0 && duration < c.conf.MinDuration {
+		return 0, fmt.Errorf("duration '%s' is shorter than %s", durationStr, c.conf.MinDuration)
+	}
+	if c.conf.MaxDuration > 0 && duration > c.conf.MaxDuration {
+		return 0, fmt.Errorf("duration '%s' is longer than %s", durationStr, c.conf.MaxDuration)
 	}
-	return time.Duration(duration) * time.Second
+	return duration, nil
 }
@@ -0,0 +1,438 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package web
+
+import (
+	"context"
+	. "flakybit.net/psl/common/events"
+	. "flakybit.net/psl/common/server"
+	. "flakybit.net/psl/lock/config"
+	. "flakybit.net/psl/lock/service"
+	"github.com/stretchr/testify/require"
+	authn "k8s.io/api/authentication/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type stubHealthChecker struct{ healthy bool }
+
+func (c stubHealthChecker) Name() string        { return "stub" }
+func (c stubHealthChecker) Run(context.Context) {}
+func (c stubHealthChecker) IsHealthy() bool     { return c.healthy }
+func (c stubHealthChecker) Reason() string      { return "" }
+
+const testPolicy = `
+rules:
+  - namespaces: [kube-system]
+    exempt: true
+  - selector: "tier=batch"
+    maxDuration: 1ms
+    pool: batch
+pools:
+  batch: 1
+`
+
+func newTestController(t *testing.T, healthy bool) *Controller {
+	return newTestControllerWithConfig(t, healthy, Config{ParallelLocks: 1, LockDuration: time.Minute})
+}
+
+func newTestControllerWithConfig(t *testing.T, healthy bool, conf Config) *Controller {
+	return newTestControllerWithEvents(t, healthy, conf, nil)
+}
+
+func newTestControllerWithEvents(t *testing.T, healthy bool, conf Config, events *PodEvents) *Controller {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	policy, err := NewPolicyFile(path)
+	require.NoError(t, err)
+	return NewController(conf, stubHealthChecker{healthy}, NewLockService(conf), policy, events)
+}
+
+func request(controller *Controller, query string) int {
+	return requestWithBody(controller, query).Code
+}
+
+func requestWithBody(controller *Controller, query string) *httptest.ResponseRecorder {
+	return requestWithPath(controller, http.MethodGet, "/?"+query)
+}
+
+func requestWithPath(controller *Controller, method string, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	controller.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+// requestAs authenticates the request as the service account with a token reviewed by a fake K8s API.
+func requestAs(t *testing.T, controller *Controller, serviceAccount string, query string) int {
+	k8s := fake.NewClientset()
+	k8s.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authn.TokenReview)
+		review.Status = authn.TokenReviewStatus{Authenticated: true, User: authn.UserInfo{Username: review.Spec.Token}}
+		return true, review, nil
+	})
+	authenticator, err := NewAuthenticator(AuthConfig{Mode: AuthTokenReview}, k8s)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	httpRequest.Header.Set("Authorization", "Bearer system:serviceaccount:"+serviceAccount)
+	authenticator.Wrap(controller).ServeHTTP(recorder, httpRequest)
+	return recorder.Code
+}
+
+func TestControllerIfExempt(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, false)
+
+	// WHEN
+	status := requestAs(t, controller, "kube-system:kube-proxy", "")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerIfExemptUnverified(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, false)
+
+	// WHEN
+	requestedStatus := request(controller, "namespace=kube-system")
+	spoofedStatus := requestAs(t, controller, "default:app", "namespace=kube-system")
+
+	// THEN
+	require.Equal(t, http.StatusLocked, requestedStatus)
+	require.Equal(t, http.StatusLocked, spoofedStatus)
+}
+
+func TestControllerAcquiresInPool(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "namespace=default")
+
+	// WHEN
+	firstStatus := request(controller, "namespace=jobs&labels=tier%3Dbatch")
+	secondStatus := request(controller, "namespace=jobs&labels=tier%3Dbatch")
+
+	// THEN
+	require.Equal(t, http.StatusOK, firstStatus)
+	require.Equal(t, http.StatusLocked, secondStatus)
+}
+
+func TestControllerTellsDenialReason(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "labels=tier%3Dbatch")
+
+	// WHEN
+	poolResponse := requestWithBody(controller, "labels=tier%3Dbatch")
+	unhealthyResponse := requestWithBody(newTestController(t, false), "")
+
+	// THEN
+	require.Equal(t, "no free lock in pool 'batch'", poolResponse.Header().Get(HeaderDenialReason))
+	require.Equal(t, "Locked", poolResponse.Body.String())
+	require.Contains(t, unhealthyResponse.Header().Get(HeaderDenialReason), "dependent endpoints are unhealthy")
+}
+
+func TestControllerRecordsPodEvents(t *testing.T) {
+	// GIVEN
+	k8s := fake.NewClientset()
+	recorder, err := NewRecorder(k8s, "psl-lock")
+	require.NoError(t, err)
+	events := NewPodEvents(EventsConfig{Enabled: true, ThrottledInterval: time.Minute}, recorder)
+	controller := newTestControllerWithEvents(t, true, Config{ParallelLocks: 1, LockDuration: time.Minute}, events)
+	request(controller, "namespace=default&pod=app-0")
+
+	// WHEN
+	response := requestWithBody(controller, "namespace=default&pod=app-1&uid=5c2d")
+
+	// THEN
+	require.Equal(t, "1", response.Header().Get(HeaderQueuePosition))
+	var recorded []core.Event
+	require.Eventually(t, func() bool {
+		list, err := k8s.CoreV1().Events("default").List(context.Background(), meta.ListOptions{})
+		require.NoError(t, err)
+		recorded = list.Items
+		return len(recorded) == 2
+	}, time.Second, 5*time.Millisecond)
+	reasons := map[string]string{}
+	for _, event := range recorded {
+		reasons[event.InvolvedObject.Name] = event.Reason
+	}
+	require.Equal(t, map[string]string{"app-0": ReasonStartupLockAcquired, "app-1": ReasonStartupThrottled}, reasons)
+}
+
+func TestControllerCapsDurationByPolicy(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "labels=tier%3Dbatch&duration=3600")
+	time.Sleep(2 * time.Millisecond)
+
+	// WHEN
+	status := request(controller, "labels=tier%3Dbatch")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerAcceptsGoDuration(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "duration=1ms")
+	time.Sleep(2 * time.Millisecond)
+
+	// WHEN
+	status := request(controller, "duration=1m30s")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerIfDurationInvalid(t *testing.T) {
+	tests := map[string]struct {
+		duration string
+		message  string
+	}{
+		"negative":     {"-5", "duration '-5' is not positive"},
+		"zero":         {"0s", "duration '0s' is not positive"},
+		"non-numeric":  {"soon", "duration 'soon' is neither Go duration like '1m30s' nor number of seconds"},
+		"below-bounds": {"500ms", "duration '500ms' is shorter than 1s"},
+		"above-bounds": {"86400", "duration '86400' is longer than 10m0s"},
+		"overflowing":  {"18446744074", "duration '18446744074' is longer than 10m0s"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN
+			conf := Config{ParallelLocks: 1, LockDuration: time.Minute, MinDuration: time.Second, MaxDuration: 10 * time.Minute}
+			controller := newTestControllerWithConfig(t, true, conf)
+
+			// WHEN
+			response := requestWithBody(controller, "duration="+test.duration)
+
+			// THEN
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.Equal(t, test.message, response.Body.String())
+		})
+	}
+}
+
+func TestControllerIfDurationOverflows(t *testing.T) {
+	tests := map[string]struct {
+		duration string
+		message  string
+	}{
+		"positive":     {"18446744074", "duration '18446744074' is out of range"},
+		"negative":     {"-18446744074", "duration '-18446744074' is not positive"},
+		"beyond-int64": {"99999999999999999999", "duration '99999999999999999999' is out of range"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN
+			controller := newTestController(t, true)
+
+			// WHEN
+			response := requestWithBody(controller, "duration="+test.duration)
+
+			// THEN
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.Equal(t, test.message, response.Body.String())
+		})
+	}
+}
+
+func TestControllerIfDurationInvalidDoesNotAcquire(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "duration=-5")
+
+	// WHEN
+	status := request(controller, "")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerAcquiresInRequestedPool(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "namespace=default")
+
+	// WHEN
+	status := requestAs(t, controller, "default:app", "pool=batch")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerAcquiresInRequestedPoolOfRule(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+
+	// WHEN
+	status := request(controller, "labels=tier%3Dbatch&pool=batch")
+
+	// THEN
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerIfRequestedPoolForbidden(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "namespace=default")
+
+	// WHEN
+	response := requestWithBody(controller, "namespace=default&pool=batch")
+
+	// THEN
+	require.Equal(t, http.StatusForbidden, response.Code)
+	require.Equal(t, "pool 'batch' may not be requested", response.Body.String())
+}
+
+func TestControllerIfRequestedPoolUndefined(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+
+	// WHEN
+	response := requestWithBody(controller, "pool=missing")
+
+	// THEN
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	require.Equal(t, "pool 'missing' is not defined", response.Body.String())
+}
+
+func TestControllerIfPriorityInvalid(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+
+	// WHEN
+	response := requestWithBody(controller, "priority=high")
+
+	// THEN
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	require.Equal(t, "priority 'high' is not integer", response.Body.String())
+}
+
+func TestControllerIgnoresPriorityOfUnverifiedCaller(t *testing.T) {
+	// GIVEN
+	controller := newTestControllerWithConfig(t, true, Config{ParallelLocks: 1, LockDuration: time.Minute, MaxPriority: 100})
+	request(controller, "namespace=default&pod=holder")
+	request(controller, "namespace=default&pod=first")
+
+	// WHEN
+	request(controller, "namespace=default&pod=jumper&priority=10")
+	response := requestWithBody(controller, "namespace=default&pod=first")
+
+	// THEN
+	require.Equal(t, http.StatusLocked, response.Code)
+	require.Equal(t, "1", response.Header().Get(HeaderQueuePosition))
+}
+
+func TestControllerHonoursPriorityOfVerifiedCaller(t *testing.T) {
+	// GIVEN
+	controller := newTestControllerWithConfig(t, true, Config{ParallelLocks: 1, LockDuration: time.Minute, MaxPriority: 100})
+	request(controller, "namespace=default&pod=holder")
+	request(controller, "namespace=default&pod=first")
+
+	// WHEN
+	requestAs(t, controller, "default:jumper", "pod=jumper&priority=10")
+	response := requestWithBody(controller, "namespace=default&pod=first")
+
+	// THEN
+	require.Equal(t, http.StatusLocked, response.Code)
+	require.Equal(t, "2", response.Header().Get(HeaderQueuePosition))
+}
+
+func TestControllerBoundsPriority(t *testing.T) {
+	tests := map[string]struct {
+		caller   Caller
+		rule     *PolicyRule
+		request  lockRequest
+		priority int
+	}{
+		"default":                     {Caller{}, nil, lockRequest{}, 0},
+		"of-rule":                     {Caller{}, &PolicyRule{Priority: -5}, lockRequest{}, -5},
+		"of-rule-below-bounds":        {Caller{}, &PolicyRule{Priority: -50}, lockRequest{}, -10},
+		"requested-verified":          {Caller{Verified: true}, &PolicyRule{Priority: -5}, lockRequest{priority: 5, priorityRequested: true}, 5},
+		"requested-verified-too-high": {Caller{Verified: true}, nil, lockRequest{priority: 50, priorityRequested: true}, 10},
+		"requested-unverified":        {Caller{}, &PolicyRule{Priority: -5}, lockRequest{priority: 5, priorityRequested: true}, -5},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN
+			controller := newTestControllerWithConfig(t, true, Config{ParallelLocks: 1, MinPriority: -10, MaxPriority: 10})
+
+			// WHEN
+			priority := controller.getPriority(test.caller, test.rule, test.request)
+
+			// THEN
+			require.Equal(t, test.priority, priority)
+		})
+	}
+}
+
+func TestControllerReleasesLease(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	acquired := requestWithBody(controller, "")
+	token := acquired.Header().Get(HeaderLeaseToken)
+
+	// WHEN
+	recorder := requestWithPath(controller, http.MethodPost, "/release?token="+token)
+	status := request(controller, "")
+
+	// THEN
+	require.Equal(t, "1m0s", acquired.Header().Get(HeaderLeaseDuration))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestControllerIfHeartbeatUnknownLease(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+
+	// WHEN
+	recorder := requestWithPath(controller, http.MethodPost, "/heartbeat?token=unknown")
+
+	// THEN
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestControllerStatus(t *testing.T) {
+	// GIVEN
+	controller := newTestController(t, true)
+	request(controller, "")
+
+	// WHEN
+	response := requestWithPath(controller, http.MethodGet, "/status")
+
+	// THEN
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `{
+		"healthy": true,
+		"reason": "",
+		"pools": [
+			{"name": "", "locks": 1, "limit": 1, "waiters": 0},
+			{"name": "batch", "locks": 0, "limit": 1, "waiters": 0}
+		]
+	}`, response.Body.String())
+}
@@ -38,6 +38,7 @@ This file incorporates work covered by the following copyright and permission no
 package web
 
 import (
+	. "flakybit.net/psl/common/server"
 	. "flakybit.net/psl/lock/config"
 	"fmt"
 	log "log/slog"
@@ -49,14 +50,20 @@ const readTimeout = 2 * time.Second
 const writeTimeout = 2 * time.Second
 const idleTimeout = 10 * time.Second
 
-func NewHttpServer(conf Config, controller http.Handler) *http.Server {
+// NewHttpServer creates server which serves HTTPS if TLS is configured.
+func NewHttpServer(conf Config, handler http.Handler) (*http.Server, error) {
+	tlsConfig, err := NewTlsConfig(conf.Tls)
+	if err != nil {
+		return nil, err
+	}
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", conf.BindHost, conf.BindPort),
-		Handler:      controller,
+		Handler:      handler,
+		TLSConfig:    tlsConfig,
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
 		IdleTimeout:  idleTimeout,
 	}
-	log.Info("configured web server", log.String("address", server.Addr))
-	return server
+	log.Info("configured web server", log.String("address", server.Addr), log.Bool("tls", tlsConfig != nil))
+	return server, nil
 }
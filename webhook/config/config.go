@@ -0,0 +1,114 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package config
+
+import (
+	"context"
+	"errors"
+	. "flakybit.net/psl/common/server"
+	initconfig "flakybit.net/psl/init/config"
+	"fmt"
+	"github.com/sethvargo/go-envconfig"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	log "log/slog"
+)
+
+type Config struct {
+	BindHost string    `env:"PSL_BIND_HOST"`                                      // Address to bind
+	BindPort int       `env:"PSL_BIND_PORT, default=8443"`                        // Port to bind
+	Image    string    `env:"PSL_WEBHOOK_IMAGE, required"`                        // Image of the init container to inject
+	Label    string    `env:"PSL_WEBHOOK_LABEL, default=psl.flakybit.net/inject"` // Label of namespaces and pods opting in with "true", pods opt out with "false"
+	Tls      TlsConfig `env:", prefix=PSL_TLS_"`
+
+	// Resources of the injected container, the defaults fit init of the same release with events enabled
+	Resources ResourcesConfig `env:", prefix=PSL_WEBHOOK_INIT_"`
+
+	// Init container configuration, it is read from the same env vars as init reads and passed to injected containers
+	Init initconfig.Config
+}
+
+// ResourcesConfig holds quantities like "20m" or "32Mi", empty ones are not set.
+type ResourcesConfig struct {
+	CpuRequest    string `env:"CPU_REQUEST, default=20m"`
+	CpuLimit      string `env:"CPU_LIMIT, default=100m"`
+	MemoryRequest string `env:"MEMORY_REQUEST, default=32Mi"`
+	MemoryLimit   string `env:"MEMORY_LIMIT, default=64Mi"`
+}
+
+// Requirements returns the resource requirements, quantities are expected to be validated.
+func (c ResourcesConfig) Requirements() core.ResourceRequirements {
+	return core.ResourceRequirements{
+		Requests: resourceList(c.CpuRequest, c.MemoryRequest),
+		Limits:   resourceList(c.CpuLimit, c.MemoryLimit),
+	}
+}
+
+func resourceList(cpu string, memory string) core.ResourceList {
+	list := core.ResourceList{}
+	if cpu != "" {
+		list[core.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[core.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+func (c ResourcesConfig) Validate() error {
+	return errors.Join(
+		validateQuantity("CPU request", c.CpuRequest),
+		validateQuantity("CPU limit", c.CpuLimit),
+		validateQuantity("memory request", c.MemoryRequest),
+		validateQuantity("memory limit", c.MemoryLimit))
+}
+
+func validateQuantity(name string, quantity string) error {
+	if _, err := resource.ParseQuantity(quantity); quantity != "" && err != nil {
+		return fmt.Errorf("init container %s '%s' is not quantity", name, quantity)
+	}
+	return nil
+}
+
+func NewConfig(ctx context.Context) (Config, error) {
+	var conf Config
+	err := envconfig.Process(ctx, &conf)
+	if err != nil {
+		return conf, err
+	}
+	err = conf.validate()
+	if err != nil {
+		return conf, err
+	}
+	log.Info("application configured", log.Any("config", conf))
+	return conf, err
+}
+
+func (c *Config) validate() error {
+	var tlsError error
+	if !c.Tls.Enabled() {
+		tlsError = errors.New("TLS certificate and key are required, API server calls webhooks with HTTPS only")
+	}
+	var labelError error
+	if c.Label == "" {
+		labelError = errors.New("opt-in label is empty")
+	}
+	var initError error
+	if err := c.Init.ValidateCommon(); err != nil {
+		initError = fmt.Errorf("invalid init configuration: %w", err)
+	}
+	return errors.Join(tlsError, c.Tls.Validate(), labelError, c.Resources.Validate(), initError)
+}
@@ -0,0 +1,96 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	. "flakybit.net/psl/common/server"
+	initconfig "flakybit.net/psl/init/config"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"testing"
+	"time"
+)
+
+func newTestConfig() Config {
+	return Config{
+		Image:     "psl/init:test",
+		Label:     "psl.flakybit.net/inject",
+		Tls:       TlsConfig{CertFile: "tls.crt", KeyFile: "tls.key"},
+		Resources: ResourcesConfig{CpuRequest: "20m", MemoryRequest: "32Mi", MemoryLimit: "64Mi"},
+		Init: initconfig.Config{
+			Mode:      initconfig.ModeSidecar,
+			LockHost:  "lock.psl.svc.cluster.local",
+			Period:    3 * time.Second,
+			OnTimeout: initconfig.OnTimeoutFail,
+			Sidecar:   initconfig.SidecarConfig{ReadinessPeriod: 2 * time.Second},
+		},
+	}
+}
+
+func TestValidateAllowsReadinessUrlByAnnotation(t *testing.T) {
+	// GIVEN
+	conf := newTestConfig()
+
+	// WHEN
+	err := conf.validate()
+
+	// THEN
+	require.NoError(t, err)
+}
+
+func TestValidateIfInitInvalid(t *testing.T) {
+	// GIVEN
+	conf := newTestConfig()
+	conf.Init.Mode = "daemon"
+	conf.Init.OnTimeout = "retry"
+	conf.Init.Period = 0
+
+	// WHEN
+	err := conf.validate()
+
+	// THEN
+	require.ErrorContains(t, err, "invalid init configuration")
+	require.ErrorContains(t, err, "mode 'daemon'")
+	require.ErrorContains(t, err, "on timeout action 'retry'")
+	require.ErrorContains(t, err, "lock check period is not positive")
+}
+
+func TestValidateIfResourcesInvalid(t *testing.T) {
+	// GIVEN
+	conf := newTestConfig()
+	conf.Resources.MemoryLimit = "64MB!"
+
+	// WHEN
+	err := conf.validate()
+
+	// THEN
+	require.EqualError(t, err, "init container memory limit '64MB!' is not quantity")
+}
+
+func TestResourcesRequirements(t *testing.T) {
+	// GIVEN
+	conf := newTestConfig()
+
+	// WHEN
+	requirements := conf.Resources.Requirements()
+
+	// THEN
+	require.Equal(t, "20m", requirements.Requests.Cpu().String())
+	require.Equal(t, "32Mi", requirements.Requests.Memory().String())
+	require.Equal(t, "64Mi", requirements.Limits.Memory().String())
+	require.NotContains(t, requirements.Limits, core.ResourceCPU)
+}
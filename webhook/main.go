@@ -0,0 +1,88 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"context"
+	. "flakybit.net/psl/common/server"
+	. "flakybit.net/psl/webhook/config"
+	. "flakybit.net/psl/webhook/service"
+	. "flakybit.net/psl/webhook/web"
+	slogenv "github.com/cbrewster/slog-env"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	log "log/slog"
+	"os"
+)
+
+func main() {
+	var err error
+	ctx := context.Background()
+
+	logHandler := slogenv.NewHandler(
+		log.NewTextHandler(os.Stderr, nil),
+		slogenv.WithEnvVarName("PSL_LOG"))
+	log.SetDefault(log.New(logHandler))
+
+	conf, err := NewConfig(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure application", log.Any("error", err))
+		panic(err)
+	}
+
+	k8sConfig, err := rest.InClusterConfig()
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure K8s client", log.Any("error", err))
+		panic(err)
+	}
+	k8s, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure K8s client", log.Any("error", err))
+		panic(err)
+	}
+
+	// only opted-in namespaces are cached, pods of others are not injected unless labelled
+	namespaceInformers := informers.NewSharedInformerFactoryWithOptions(k8s, 0,
+		informers.WithTweakListOptions(func(opt *meta.ListOptions) {
+			opt.LabelSelector = conf.Label + "=true"
+		}))
+	namespaces := namespaceInformers.Core().V1().Namespaces()
+	namespaceLister := namespaces.Lister()
+	namespaceInformers.Start(ctx.Done())
+	log.Info("waiting for Namespace informer to sync")
+	if !cache.WaitForCacheSync(ctx.Done(), namespaces.Informer().HasSynced) {
+		log.ErrorContext(ctx, "failed to sync Namespace informer")
+		panic("failed to sync Namespace informer")
+	}
+
+	injector := NewInjector(conf, namespaceLister)
+	controller := NewController(injector)
+	httpServer, err := NewHttpServer(conf, controller)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to configure http server", log.Any("error", err))
+		panic(err)
+	}
+	err = ListenAndServe(httpServer)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to start http server", log.Any("error", err))
+		panic(err)
+	}
+
+	select {} // Wait forever and let child goroutines run
+}
@@ -0,0 +1,221 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package service
+
+import (
+	"context"
+	initconfig "flakybit.net/psl/init/config"
+	. "flakybit.net/psl/webhook/config"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	log "log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	InitContainerName = "psl"
+	podInfoVolumeName = "psl-podinfo"
+	podInfoMountPath  = "/etc/psl/podinfo"
+
+	AnnotationDuration     = "psl.flakybit.net/duration"
+	AnnotationPool         = "psl.flakybit.net/pool"
+	AnnotationPriority     = "psl.flakybit.net/priority"
+	AnnotationReadinessUrl = "psl.flakybit.net/readiness-url"
+
+	// sidecarStartupFailureThreshold lets the sidecar wait for the lock for an hour
+	sidecarStartupFailureThreshold = 3600
+)
+
+// PatchOperation is an operation of JSON patch, see RFC 6902.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Injector adds the init container to pods which opted in.
+type Injector struct {
+	conf       Config
+	namespaces corelisters.NamespaceLister // Cached namespaces, only opted-in ones may be cached
+}
+
+func NewInjector(conf Config, namespaces corelisters.NamespaceLister) *Injector {
+	injector := &Injector{conf, namespaces}
+	log.Info("configured injector", log.String("image", conf.Image), log.String("label", conf.Label))
+	return injector
+}
+
+// Inject returns JSON patch adding the init container to the pod, or nil if the pod did not opt in
+// or already has the container. Warnings explain ignored annotations.
+func (i *Injector) Inject(ctx context.Context, namespace string, pod *core.Pod) ([]PatchOperation, []string, error) {
+	optedIn, err := i.isOptedIn(namespace, pod)
+	if err != nil || !optedIn {
+		return nil, nil, err
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == InitContainerName {
+			return nil, nil, nil
+		}
+	}
+	if i.conf.Init.Mode == initconfig.ModeSidecar && i.conf.Init.Sidecar.ReadinessUrl == "" && pod.Annotations[AnnotationReadinessUrl] == "" {
+		// the sidecar would fail to start, and the pod with it
+		return nil, []string{fmt.Sprintf("init container is not injected, sidecar mode requires annotation %s", AnnotationReadinessUrl)}, nil
+	}
+
+	container, warnings := i.initContainer(pod)
+	var patch []PatchOperation
+	if len(pod.Spec.InitContainers) == 0 {
+		patch = append(patch, PatchOperation{"add", "/spec/initContainers", []core.Container{container}})
+	} else {
+		// the lock must be acquired before any other init container starts
+		patch = append(patch, PatchOperation{"add", "/spec/initContainers/0", container})
+	}
+	if len(pod.Spec.Volumes) == 0 {
+		patch = append(patch, PatchOperation{"add", "/spec/volumes", []core.Volume{podInfoVolume()}})
+	} else {
+		patch = append(patch, PatchOperation{"add", "/spec/volumes/-", podInfoVolume()})
+	}
+	return patch, warnings, nil
+}
+
+// isOptedIn checks the opt-in label of the pod, and of its namespace unless the pod opted out.
+func (i *Injector) isOptedIn(namespace string, pod *core.Pod) (bool, error) {
+	switch pod.Labels[i.conf.Label] {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	ns, err := i.namespaces.Get(namespace)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get namespace '%s': %w", namespace, err)
+	}
+	return ns.Labels[i.conf.Label] == "true", nil
+}
+
+func (i *Injector) initContainer(pod *core.Pod) (core.Container, []string) {
+	env := initEnv(i.conf.Init)
+	var warnings []string
+	if duration, found := pod.Annotations[AnnotationDuration]; found {
+		if _, err := time.ParseDuration(duration); err == nil {
+			env = setEnv(env, "PSL_LOCK_DURATION", duration)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is ignored, '%s' is not duration", AnnotationDuration, duration))
+		}
+	}
+	if pool, found := pod.Annotations[AnnotationPool]; found {
+		env = setEnv(env, "PSL_LOCK_POOL", pool)
+	}
+	if priority, found := pod.Annotations[AnnotationPriority]; found {
+		if _, err := strconv.Atoi(priority); err == nil {
+			env = setEnv(env, "PSL_LOCK_PRIORITY", priority)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is ignored, '%s' is not integer", AnnotationPriority, priority))
+		}
+	}
+	if url, found := pod.Annotations[AnnotationReadinessUrl]; found {
+		env = setEnv(env, "PSL_SIDECAR_READINESS_URL", url)
+	}
+	env = append(env,
+		core.EnvVar{Name: "PSL_POD_NAMESPACE", ValueFrom: fieldRef("metadata.namespace")},
+		core.EnvVar{Name: "PSL_POD_NAME", ValueFrom: fieldRef("metadata.name")},
+		core.EnvVar{Name: "PSL_POD_UID", ValueFrom: fieldRef("metadata.uid")},
+		core.EnvVar{Name: "PSL_POD_LABELS_FILE", Value: podInfoMountPath + "/labels"},
+	)
+
+	container := core.Container{
+		Name:         InitContainerName,
+		Image:        i.conf.Image,
+		Env:          env,
+		Resources:    i.conf.Resources.Requirements(),
+		VolumeMounts: []core.VolumeMount{{Name: podInfoVolumeName, MountPath: podInfoMountPath, ReadOnly: true}},
+	}
+	if i.conf.Init.Mode == initconfig.ModeSidecar {
+		// native sidecar, app containers start once its startup probe succeeds, i.e. the lock is acquired
+		restartPolicy := core.ContainerRestartPolicyAlways
+		container.RestartPolicy = &restartPolicy
+		container.StartupProbe = &core.Probe{
+			ProbeHandler: core.ProbeHandler{
+				HTTPGet: &core.HTTPGetAction{Port: intstr.FromInt32(int32(i.conf.Init.Sidecar.ProbePort))},
+			},
+			PeriodSeconds:    1,
+			FailureThreshold: sidecarStartupFailureThreshold,
+		}
+	}
+	return container, warnings
+}
+
+// initEnv renders non-zero fields of init config to env vars of the same names as init reads.
+// Pod identity is excluded, since it differs by pod.
+func initEnv(conf initconfig.Config) []core.EnvVar {
+	return appendEnv(nil, "", reflect.ValueOf(conf))
+}
+
+func appendEnv(env []core.EnvVar, prefix string, value reflect.Value) []core.EnvVar {
+	for n := 0; n < value.NumField(); n++ {
+		name, options, _ := strings.Cut(value.Type().Field(n).Tag.Get("env"), ",")
+		field := value.Field(n)
+		if nestedPrefix, found := strings.CutPrefix(strings.TrimSpace(options), "prefix="); found && field.Kind() == reflect.Struct {
+			env = appendEnv(env, prefix+nestedPrefix, field)
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || field.IsZero() || strings.HasPrefix(prefix+name, "PSL_POD_") {
+			continue
+		}
+		name = prefix + name
+		var str string
+		if duration, ok := field.Interface().(time.Duration); ok {
+			str = duration.String()
+		} else {
+			str = fmt.Sprint(field.Interface())
+		}
+		env = append(env, core.EnvVar{Name: name, Value: str})
+	}
+	return env
+}
+
+func setEnv(env []core.EnvVar, name, value string) []core.EnvVar {
+	for n := range env {
+		if env[n].Name == name {
+			env[n].Value = value
+			return env
+		}
+	}
+	return append(env, core.EnvVar{Name: name, Value: value})
+}
+
+func fieldRef(path string) *core.EnvVarSource {
+	return &core.EnvVarSource{FieldRef: &core.ObjectFieldSelector{FieldPath: path}}
+}
+
+func podInfoVolume() core.Volume {
+	return core.Volume{
+		Name: podInfoVolumeName,
+		VolumeSource: core.VolumeSource{DownwardAPI: &core.DownwardAPIVolumeSource{
+			Items: []core.DownwardAPIVolumeFile{{Path: "labels", FieldRef: &core.ObjectFieldSelector{FieldPath: "metadata.labels"}}},
+		}},
+	}
+}
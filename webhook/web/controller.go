@@ -0,0 +1,103 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package web
+
+import (
+	"encoding/json"
+	. "flakybit.net/psl/webhook/service"
+	admission "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	log "log/slog"
+	"net/http"
+)
+
+// maxReviewSize bounds AdmissionReview request body, API server limits objects to 3MiB.
+const maxReviewSize = 4 << 20
+
+type Controller struct {
+	injector *Injector
+}
+
+func NewController(injector *Injector) *Controller {
+	controller := &Controller{injector}
+	log.Info("configured web controller")
+	return controller
+}
+
+// ServeHTTP responds to AdmissionReview with JSON patch injecting the init container.
+// Pods are always allowed, failures to inject are reported as warnings not to block workloads.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admission.AdmissionReview
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReviewSize)).Decode(&review)
+	if err != nil || review.Request == nil {
+		log.Error("invalid admission review", log.String("client-ip", r.RemoteAddr), log.Any("error", err))
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+
+	request := review.Request
+	response := &admission.AdmissionResponse{UID: request.UID, Allowed: true}
+	if request.Operation == admission.Create && request.Kind.Kind == "Pod" && request.Kind.Group == "" {
+		c.inject(r, request, response)
+	}
+	review.Request, review.Response = nil, response
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		log.Error("failed to respond to admission review",
+			log.String("client-ip", r.RemoteAddr),
+			log.Any("error", err))
+	}
+}
+
+func (c *Controller) inject(r *http.Request, request *admission.AdmissionRequest, response *admission.AdmissionResponse) {
+	var pod core.Pod
+	err := json.Unmarshal(request.Object.Raw, &pod)
+	if err != nil {
+		log.Error("invalid pod in admission review", log.String("uid", string(request.UID)), log.Any("error", err))
+		response.Warnings = append(response.Warnings, "psl init container is not injected, pod is malformed")
+		return
+	}
+	// pods of controllers have no name yet
+	name := pod.Name
+	if name == "" {
+		name = pod.GenerateName
+	}
+
+	patch, warnings, err := c.injector.Inject(r.Context(), request.Namespace, &pod)
+	response.Warnings = append(response.Warnings, warnings...)
+	if err != nil {
+		log.Error("failed to inject init container",
+			log.String("namespace", request.Namespace),
+			log.String("pod", name),
+			log.Any("error", err))
+		response.Warnings = append(response.Warnings, "psl init container is not injected: "+err.Error())
+		return
+	}
+	if patch == nil {
+		return
+	}
+	response.Patch, err = json.Marshal(patch)
+	if err != nil {
+		log.Error("failed to encode patch", log.String("uid", string(request.UID)), log.Any("error", err))
+		response.Patch = nil
+		return
+	}
+	patchType := admission.PatchTypeJSONPatch
+	response.PatchType = &patchType
+	log.Info("injected init container", log.String("namespace", request.Namespace), log.String("pod", name))
+}
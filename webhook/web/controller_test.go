@@ -0,0 +1,238 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	initconfig "flakybit.net/psl/init/config"
+	. "flakybit.net/psl/webhook/config"
+	. "flakybit.net/psl/webhook/service"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/evanphx/json-patch.v4"
+	admission "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var conf = Config{
+	Image:     "psl/init:test",
+	Label:     "psl.flakybit.net/inject",
+	Resources: ResourcesConfig{MemoryRequest: "32Mi", MemoryLimit: "64Mi"},
+	Init: initconfig.Config{
+		LockHost:     "lock.psl.svc.cluster.local",
+		LockPort:     8080,
+		LockDuration: 20 * time.Second,
+		Period:       3 * time.Second,
+		Timeout:      time.Second,
+	},
+}
+
+func newTestController() *Controller {
+	return newTestControllerWithConfig(conf)
+}
+
+func newTestControllerWithConfig(conf Config) *Controller {
+	namespaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	_ = namespaces.Add(&core.Namespace{ObjectMeta: meta.ObjectMeta{Name: "batch", Labels: map[string]string{"psl.flakybit.net/inject": "true"}}})
+	return NewController(NewInjector(conf, corelisters.NewNamespaceLister(namespaces)))
+}
+
+// review sends the fixture and returns the response and the pod with the patch applied.
+func review(t *testing.T, fixture string) (*admission.AdmissionResponse, *core.Pod) {
+	return reviewWithConfig(t, conf, fixture)
+}
+
+func reviewWithConfig(t *testing.T, conf Config, fixture string) (*admission.AdmissionResponse, *core.Pod) {
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	newTestControllerWithConfig(conf).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var request, response admission.AdmissionReview
+	require.NoError(t, json.Unmarshal(body, &request))
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, request.Request.UID, response.Response.UID)
+	require.True(t, response.Response.Allowed)
+
+	podJson := request.Request.Object.Raw
+	if response.Response.Patch != nil {
+		require.Equal(t, admission.PatchTypeJSONPatch, *response.Response.PatchType)
+		patch, err := jsonpatch.DecodePatch(response.Response.Patch)
+		require.NoError(t, err)
+		podJson, err = patch.Apply(podJson)
+		require.NoError(t, err)
+	}
+	var pod core.Pod
+	require.NoError(t, json.Unmarshal(podJson, &pod))
+	return response.Response, &pod
+}
+
+func envOf(container core.Container) map[string]string {
+	env := map[string]string{}
+	for _, envVar := range container.Env {
+		if envVar.ValueFrom != nil {
+			env[envVar.Name] = envVar.ValueFrom.FieldRef.FieldPath
+		} else {
+			env[envVar.Name] = envVar.Value
+		}
+	}
+	return env
+}
+
+func TestInjectIfPodOptedIn(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, pod := review(t, "pod-opted-in.json")
+
+	// THEN
+	require.Empty(t, response.Warnings)
+	require.Len(t, pod.Spec.InitContainers, 1)
+	container := pod.Spec.InitContainers[0]
+	require.Equal(t, InitContainerName, container.Name)
+	require.Equal(t, "psl/init:test", container.Image)
+	require.Equal(t, map[string]string{
+		"PSL_LOCK_HOST":          "lock.psl.svc.cluster.local",
+		"PSL_LOCK_PORT":          "8080",
+		"PSL_LOCK_DURATION":      "20s",
+		"PSL_LOCK_CHECK_PERIOD":  "3s",
+		"PSL_LOCK_CHECK_TIMEOUT": "1s",
+		"PSL_POD_NAMESPACE":      "metadata.namespace",
+		"PSL_POD_NAME":           "metadata.name",
+		"PSL_POD_UID":            "metadata.uid",
+		"PSL_POD_LABELS_FILE":    "/etc/psl/podinfo/labels",
+	}, envOf(container))
+	require.Equal(t, "64Mi", container.Resources.Limits.Memory().String())
+	require.Len(t, pod.Spec.Volumes, 1)
+	require.Equal(t, container.VolumeMounts[0].Name, pod.Spec.Volumes[0].Name)
+}
+
+func TestInjectBeforeOtherInitContainers(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, pod := review(t, "pod-with-init-containers.json")
+
+	// THEN
+	require.Len(t, pod.Spec.InitContainers, 2)
+	require.Equal(t, InitContainerName, pod.Spec.InitContainers[0].Name)
+	require.Equal(t, "migrate", pod.Spec.InitContainers[1].Name)
+	require.Len(t, pod.Spec.Volumes, 2)
+	require.Equal(t, "data", pod.Spec.Volumes[0].Name)
+}
+
+func TestInjectIfNamespaceOptedIn(t *testing.T) {
+	// GIVEN
+	// WHEN
+	_, pod := review(t, "pod-namespace-opted-in.json")
+
+	// THEN
+	require.Len(t, pod.Spec.InitContainers, 1)
+}
+
+func TestInjectNotIfPodOptedOut(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, pod := review(t, "pod-opted-out.json")
+
+	// THEN
+	require.Nil(t, response.Patch)
+	require.Empty(t, pod.Spec.InitContainers)
+}
+
+func TestInjectNotIfNotOptedIn(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, _ := review(t, "pod-not-opted-in.json")
+
+	// THEN
+	require.Nil(t, response.Patch)
+}
+
+func TestInjectNotIfAlreadyInjected(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, pod := review(t, "pod-already-injected.json")
+
+	// THEN
+	require.Nil(t, response.Patch)
+	require.Len(t, pod.Spec.InitContainers, 1)
+}
+
+func TestInjectNotIfUpdate(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, _ := review(t, "pod-update.json")
+
+	// THEN
+	require.Nil(t, response.Patch)
+}
+
+func TestInjectWithAnnotations(t *testing.T) {
+	// GIVEN
+	// WHEN
+	response, pod := review(t, "pod-annotations.json")
+
+	// THEN
+	env := envOf(pod.Spec.InitContainers[0])
+	require.Equal(t, "45s", env["PSL_LOCK_DURATION"])
+	require.Equal(t, "databases", env["PSL_LOCK_POOL"])
+	require.NotContains(t, env, "PSL_LOCK_PRIORITY")
+	require.Equal(t, []string{"annotation psl.flakybit.net/priority is ignored, 'high' is not integer"}, response.Warnings)
+}
+
+func TestInjectIfReviewMalformed(t *testing.T) {
+	// GIVEN
+	recorder := httptest.NewRecorder()
+
+	// WHEN
+	newTestController().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{"))))
+
+	// THEN
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestInjectSidecar(t *testing.T) {
+	// GIVEN
+	sidecarConf := conf
+	sidecarConf.Init.Mode = initconfig.ModeSidecar
+	sidecarConf.Init.Sidecar = initconfig.SidecarConfig{ReadinessPeriod: 2 * time.Second, ProbePort: 8081}
+
+	// WHEN
+	_, pod := reviewWithConfig(t, sidecarConf, "pod-sidecar.json")
+	noUrlResponse, noUrlPod := reviewWithConfig(t, sidecarConf, "pod-opted-in.json")
+
+	// THEN
+	require.Empty(t, noUrlPod.Spec.InitContainers)
+	require.Len(t, noUrlResponse.Warnings, 1)
+	require.Contains(t, noUrlResponse.Warnings[0], "psl.flakybit.net/readiness-url")
+	container := pod.Spec.InitContainers[0]
+	require.Equal(t, core.ContainerRestartPolicyAlways, *container.RestartPolicy)
+	require.Equal(t, int32(8081), container.StartupProbe.HTTPGet.Port.IntVal)
+	env := envOf(container)
+	require.Equal(t, "sidecar", env["PSL_MODE"])
+	require.Equal(t, "2s", env["PSL_SIDECAR_READINESS_PERIOD"])
+	require.Equal(t, "8081", env["PSL_SIDECAR_PROBE_PORT"])
+	require.Equal(t, "http://localhost:8080/ready", env["PSL_SIDECAR_READINESS_URL"])
+}
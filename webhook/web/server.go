@@ -0,0 +1,49 @@
+/*
+This file is part of PSL (Pod Startup Lock).
+Copyright (c) 2024, The PSL (Pod Startup Lock) Authors
+
+PSL (Pod Startup Lock) is free software:
+you can redistribute it and/or modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation, version 3 of the License.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program.
+If not, see <https://www.gnu.org/licenses/>.
+*/
+package web
+
+import (
+	. "flakybit.net/psl/common/server"
+	. "flakybit.net/psl/webhook/config"
+	"fmt"
+	log "log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	readTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second // API server calls webhooks with 10s timeout by default
+	idleTimeout  = 60 * time.Second
+)
+
+// NewHttpServer creates HTTPS server.
+func NewHttpServer(conf Config, handler http.Handler) (*http.Server, error) {
+	tlsConfig, err := NewTlsConfig(conf.Tls)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", conf.BindHost, conf.BindPort),
+		Handler:      handler,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+	log.Info("configured web server", log.String("address", server.Addr), log.Bool("tls", tlsConfig != nil))
+	return server, nil
+}